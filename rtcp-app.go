@@ -0,0 +1,80 @@
+package rtp
+
+/*
+Application-Defined packets are defined in
+https://tools.ietf.org/html/rfc3550#section-6.7
+
+    0                   1                   2                   3
+    0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |V=2|P| subtype |   PT=APP=204  |             length            |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |                           SSRC/CSRC                           |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |                          name (ASCII)                         |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |                   application-dependent data                ...
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+*/
+
+import (
+	"errors"
+)
+
+// ApplicationDefined is a RTCP APP packet. Data is zero padded to 32 bits
+// when marshaled.
+type ApplicationDefined struct {
+	SubType uint8
+	SSRC    uint32
+	Name    string
+	Data    []byte
+}
+
+func (a *ApplicationDefined) GetPT() RTCPTypeClass {
+	return RTCPTypeAPP
+}
+
+func (a *ApplicationDefined) Marshal() ([]byte, error) {
+	if len(a.Name) != 4 {
+		return nil, errors.New("rtcp: APP name must be 4 characters")
+	}
+
+	size := rtcpHeaderSize + 4 + len(a.Data)
+	if size%4 != 0 {
+		size += 4 - size%4
+	}
+	buffer := make([]byte, size)
+
+	h, err := newRTCPHeader(buffer, RTCPTypeAPP, int(a.SubType))
+	if err != nil {
+		return nil, err
+	}
+	h.SetSenderSSRC(a.SSRC)
+
+	copy(buffer[rtcpHeaderSize:], a.Name)
+	copy(buffer[rtcpHeaderSize+4:], a.Data)
+
+	return buffer, nil
+}
+
+func (a *ApplicationDefined) Unmarshal(buffer []byte) error {
+	return unmarshalRTCP(buffer, a)
+}
+
+func (a *ApplicationDefined) unmarshalPacket(p *RTCPPacket) error {
+	if p.GetPT() != RTCPTypeAPP {
+		return errors.New("rtcp: packet is not an APP")
+	}
+
+	payload := p.GetPayload()
+	if len(p.header.buffer) < rtcpHeaderSize || len(payload) < 4 {
+		return errors.New("rtcp: APP too short for name")
+	}
+
+	a.SubType = uint8(p.header.GetRC())
+	a.SSRC = p.header.GetSenderSSRC()
+	a.Name = string(payload[:4])
+	a.Data = append([]byte{}, payload[4:]...)
+
+	return nil
+}
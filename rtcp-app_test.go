@@ -0,0 +1,39 @@
+package rtp
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestApplicationDefined(t *testing.T) {
+	app := &ApplicationDefined{
+		SubType: 3,
+		SSRC:    0x11223344,
+		Name:    "TEST",
+		Data:    []byte{1, 2, 3, 4, 5},
+	}
+
+	data, err := app.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	golden, _ := hex.DecodeString("83cc0004" + "11223344" + "54455354" + "01020304" + "05000000")
+	compareByteArrays(t, data, golden)
+
+	var app2 ApplicationDefined
+	err = app2.Unmarshal(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, app2.SubType, uint8(3))
+	assertEqual(t, app2.SSRC, uint32(0x11223344))
+	assertEqual(t, app2.Name, "TEST")
+	compareByteArrays(t, app2.Data, []byte{1, 2, 3, 4, 5, 0, 0, 0})
+
+	app.Name = "TOOLONG"
+	_, err = app.Marshal()
+	if err == nil {
+		t.Errorf("APP with long name was accepted")
+	}
+}
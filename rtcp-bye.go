@@ -0,0 +1,102 @@
+package rtp
+
+/*
+Goodbye is defined in https://tools.ietf.org/html/rfc3550#section-6.6
+
+       0                   1                   2                   3
+       0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+      +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+      |V=2|P|    SC   |   PT=BYE=203  |             length            |
+      +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+      |                           SSRC/CSRC                           |
+      +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+      :                              ...                              :
+      +=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+
+(opt) |     length    |               reason for leaving            ...
+      +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+*/
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Goodbye is a RTCP BYE packet
+type Goodbye struct {
+	Sources []uint32
+	Reason  string
+}
+
+func (b *Goodbye) GetPT() RTCPTypeClass {
+	return RTCPTypeBYE
+}
+
+func (b *Goodbye) Marshal() ([]byte, error) {
+	if len(b.Reason) > 255 {
+		return nil, errors.New("rtcp: BYE reason longer than 255 bytes")
+	}
+
+	size := 4 + 4*len(b.Sources)
+	if len(b.Reason) > 0 {
+		size += 1 + len(b.Reason)
+	}
+	if size%4 != 0 {
+		size += 4 - size%4
+	}
+	buffer := make([]byte, size)
+
+	_, err := newRTCPHeader(buffer, RTCPTypeBYE, len(b.Sources))
+	if err != nil {
+		return nil, err
+	}
+
+	offset := 4
+	for _, ssrc := range b.Sources {
+		binary.BigEndian.PutUint32(buffer[offset:], ssrc)
+		offset += 4
+	}
+
+	if len(b.Reason) > 0 {
+		buffer[offset] = byte(len(b.Reason))
+		copy(buffer[offset+1:], b.Reason)
+	}
+
+	return buffer, nil
+}
+
+func (b *Goodbye) Unmarshal(buffer []byte) error {
+	return unmarshalRTCP(buffer, b)
+}
+
+func (b *Goodbye) unmarshalPacket(p *RTCPPacket) error {
+	if p.GetPT() != RTCPTypeBYE {
+		return errors.New("rtcp: packet is not a BYE")
+	}
+
+	// the first source is in the sender SSRC position of the header
+	body := append(append([]byte{}, p.header.buffer[4:]...), p.GetPayload()...)
+
+	sc := p.header.GetRC()
+	if 4*sc > len(body) {
+		return errors.New("rtcp: BYE source list larger than packet")
+	}
+
+	sources := make([]uint32, sc)
+	for i := range sources {
+		sources[i] = binary.BigEndian.Uint32(body[4*i:])
+	}
+
+	reason := ""
+	rest := body[4*sc:]
+	if len(rest) > 0 {
+		if 1+int(rest[0]) > len(rest) {
+			return errors.New("rtcp: BYE reason larger than packet")
+		}
+		reason = string(rest[1 : 1+int(rest[0])])
+	}
+
+	b.Sources = sources
+	b.Reason = reason
+
+	return nil
+}
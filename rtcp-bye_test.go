@@ -0,0 +1,51 @@
+package rtp
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestGoodbye(t *testing.T) {
+	bye := &Goodbye{
+		Sources: []uint32{0x11223344, 0x55667788},
+		Reason:  "done",
+	}
+
+	data, err := bye.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	golden, _ := hex.DecodeString("82cb0004" + "11223344" + "55667788" + "04646f6e" + "65000000")
+	compareByteArrays(t, data, golden)
+
+	var bye2 Goodbye
+	err = bye2.Unmarshal(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(bye2.Sources), 2)
+	assertEqual(t, bye2.Sources[1], uint32(0x55667788))
+	assertEqual(t, bye2.Reason, "done")
+
+	// no reason
+	bye.Reason = ""
+	data, err = bye.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(data), 12)
+
+	err = bye2.Unmarshal(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, bye2.Reason, "")
+
+	// reason length past the end of the packet
+	data, _ = hex.DecodeString("81cb0002" + "11223344" + "09646f6e")
+	err = bye2.Unmarshal(data)
+	if err == nil {
+		t.Errorf("BYE with bad reason length was accepted")
+	}
+}
@@ -0,0 +1,332 @@
+package rtp
+
+/*
+Feedback messages are defined in https://tools.ietf.org/html/rfc4585#section-6.1
+
+    0                   1                   2                   3
+    0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |V=2|P|   FMT   |       PT      |          length               |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |                  SSRC of packet sender                        |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |                  SSRC of media source                         |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   :            Feedback Control Information (FCI)                 :
+   :                                                               :
+
+Generic NACK and PLI are in RFC4585, FIR is in
+https://tools.ietf.org/html/rfc5104#section-4.3.1 and REMB is in
+https://tools.ietf.org/html/draft-alvestrand-rmcat-remb-03
+*/
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// Feedback message types carried in the FMT field
+const (
+	FMTGenericNACK = 1
+	FMTPLI         = 1
+	FMTFIR         = 4
+	FMTAFB         = 15 // application layer feedback such as REMB
+)
+
+const (
+	feedbackHeaderSize = rtcpHeaderSize + 4
+)
+
+var rembID = []byte("REMB")
+
+// marshalFeedback allocates a feedback message with room for fciLen bytes
+// of FCI and fills in the header
+func marshalFeedback(pt RTCPTypeClass, fmt int, sender, media uint32, fciLen int) ([]byte, error) {
+	buffer := make([]byte, feedbackHeaderSize+fciLen)
+
+	h, err := newRTCPHeader(buffer, pt, fmt)
+	if err != nil {
+		return nil, err
+	}
+	h.SetSenderSSRC(sender)
+	binary.BigEndian.PutUint32(buffer[rtcpHeaderSize:], media)
+
+	return buffer, nil
+}
+
+// unmarshalFeedback checks the packet type and format and returns the
+// sender and media SSRCs and the FCI
+func unmarshalFeedback(p *RTCPPacket, pt RTCPTypeClass, fmt int) (uint32, uint32, []byte, error) {
+	if p.GetPT() != pt || p.header.GetRC() != fmt {
+		return 0, 0, nil, errors.New("rtcp: packet is not the expected feedback message")
+	}
+
+	payload := p.GetPayload()
+	if len(p.header.buffer) < rtcpHeaderSize || len(payload) < 4 {
+		return 0, 0, nil, errors.New("rtcp: feedback message too short for media SSRC")
+	}
+
+	return p.header.GetSenderSSRC(), binary.BigEndian.Uint32(payload), payload[4:], nil
+}
+
+// NackPair is a lost packet and a bitmask of the following 16 packets that
+// are also lost
+type NackPair struct {
+	PacketID    uint16
+	LostPackets uint16
+}
+
+// PacketList returns the sequence numbers of every packet in the pair
+func (n NackPair) PacketList() []uint16 {
+	seqs := []uint16{n.PacketID}
+	for i := uint16(0); i < 16; i++ {
+		if n.LostPackets&(1<<i) > 0 {
+			seqs = append(seqs, n.PacketID+i+1)
+		}
+	}
+	return seqs
+}
+
+// NackPairsFromSeqs packs a list of lost sequence numbers in ascending
+// order into NACK pairs
+func NackPairsFromSeqs(seqs []uint16) []NackPair {
+	var pairs []NackPair
+
+	for _, seq := range seqs {
+		if len(pairs) > 0 {
+			last := &pairs[len(pairs)-1]
+			diff := seq - last.PacketID
+			if diff == 0 {
+				continue
+			}
+			if diff <= 16 {
+				last.LostPackets |= 1 << (diff - 1)
+				continue
+			}
+		}
+		pairs = append(pairs, NackPair{PacketID: seq})
+	}
+
+	return pairs
+}
+
+// TransportLayerNack is a RTPFB Generic NACK
+type TransportLayerNack struct {
+	SenderSSRC uint32
+	MediaSSRC  uint32
+	Nacks      []NackPair
+}
+
+func (n *TransportLayerNack) GetPT() RTCPTypeClass {
+	return RTCPTypeRTPFB
+}
+
+func (n *TransportLayerNack) Marshal() ([]byte, error) {
+	buffer, err := marshalFeedback(RTCPTypeRTPFB, FMTGenericNACK, n.SenderSSRC, n.MediaSSRC, 4*len(n.Nacks))
+	if err != nil {
+		return nil, err
+	}
+
+	fci := buffer[feedbackHeaderSize:]
+	for i, pair := range n.Nacks {
+		binary.BigEndian.PutUint16(fci[4*i:], pair.PacketID)
+		binary.BigEndian.PutUint16(fci[4*i+2:], pair.LostPackets)
+	}
+
+	return buffer, nil
+}
+
+func (n *TransportLayerNack) Unmarshal(buffer []byte) error {
+	return unmarshalRTCP(buffer, n)
+}
+
+func (n *TransportLayerNack) unmarshalPacket(p *RTCPPacket) error {
+	sender, media, fci, err := unmarshalFeedback(p, RTCPTypeRTPFB, FMTGenericNACK)
+	if err != nil {
+		return err
+	}
+	if len(fci)%4 != 0 {
+		return errors.New("rtcp: NACK FCI not a multiple of 32 bits")
+	}
+
+	nacks := make([]NackPair, len(fci)/4)
+	for i := range nacks {
+		nacks[i].PacketID = binary.BigEndian.Uint16(fci[4*i:])
+		nacks[i].LostPackets = binary.BigEndian.Uint16(fci[4*i+2:])
+	}
+
+	n.SenderSSRC = sender
+	n.MediaSSRC = media
+	n.Nacks = nacks
+
+	return nil
+}
+
+// PictureLossIndication is a PSFB PLI
+type PictureLossIndication struct {
+	SenderSSRC uint32
+	MediaSSRC  uint32
+}
+
+func (pli *PictureLossIndication) GetPT() RTCPTypeClass {
+	return RTCPTypePSFB
+}
+
+func (pli *PictureLossIndication) Marshal() ([]byte, error) {
+	return marshalFeedback(RTCPTypePSFB, FMTPLI, pli.SenderSSRC, pli.MediaSSRC, 0)
+}
+
+func (pli *PictureLossIndication) Unmarshal(buffer []byte) error {
+	return unmarshalRTCP(buffer, pli)
+}
+
+func (pli *PictureLossIndication) unmarshalPacket(p *RTCPPacket) error {
+	sender, media, _, err := unmarshalFeedback(p, RTCPTypePSFB, FMTPLI)
+	if err != nil {
+		return err
+	}
+
+	pli.SenderSSRC = sender
+	pli.MediaSSRC = media
+
+	return nil
+}
+
+// FIREntry asks the sender of SSRC for a decoder refresh. SeqNr is
+// incremented for each new request.
+type FIREntry struct {
+	SSRC  uint32
+	SeqNr uint8
+}
+
+// FullIntraRequest is a PSFB FIR. The media SSRC is not used and is sent as
+// zero, the SSRCs asked for a refresh are in the FIR entries.
+type FullIntraRequest struct {
+	SenderSSRC uint32
+	FIR        []FIREntry
+}
+
+func (f *FullIntraRequest) GetPT() RTCPTypeClass {
+	return RTCPTypePSFB
+}
+
+func (f *FullIntraRequest) Marshal() ([]byte, error) {
+	buffer, err := marshalFeedback(RTCPTypePSFB, FMTFIR, f.SenderSSRC, 0, 8*len(f.FIR))
+	if err != nil {
+		return nil, err
+	}
+
+	fci := buffer[feedbackHeaderSize:]
+	for i, entry := range f.FIR {
+		binary.BigEndian.PutUint32(fci[8*i:], entry.SSRC)
+		fci[8*i+4] = entry.SeqNr
+	}
+
+	return buffer, nil
+}
+
+func (f *FullIntraRequest) Unmarshal(buffer []byte) error {
+	return unmarshalRTCP(buffer, f)
+}
+
+func (f *FullIntraRequest) unmarshalPacket(p *RTCPPacket) error {
+	sender, _, fci, err := unmarshalFeedback(p, RTCPTypePSFB, FMTFIR)
+	if err != nil {
+		return err
+	}
+	if len(fci)%8 != 0 {
+		return errors.New("rtcp: FIR FCI not a multiple of 64 bits")
+	}
+
+	entries := make([]FIREntry, len(fci)/8)
+	for i := range entries {
+		entries[i].SSRC = binary.BigEndian.Uint32(fci[8*i:])
+		entries[i].SeqNr = fci[8*i+4]
+	}
+
+	f.SenderSSRC = sender
+	f.FIR = entries
+
+	return nil
+}
+
+// ReceiverEstimatedMaxBitrate is a PSFB REMB. Bitrate is in bits per second
+// and is rounded down to 18 bits of mantissa when marshaled.
+type ReceiverEstimatedMaxBitrate struct {
+	SenderSSRC uint32
+	Bitrate    uint64
+	SSRCs      []uint32
+}
+
+func (r *ReceiverEstimatedMaxBitrate) GetPT() RTCPTypeClass {
+	return RTCPTypePSFB
+}
+
+func (r *ReceiverEstimatedMaxBitrate) Marshal() ([]byte, error) {
+	if len(r.SSRCs) > 255 {
+		return nil, errors.New("rtcp: REMB has more than 255 SSRCs")
+	}
+
+	exp := uint(0)
+	mantissa := r.Bitrate
+	for mantissa >= 1<<18 {
+		mantissa >>= 1
+		exp++
+	}
+
+	buffer, err := marshalFeedback(RTCPTypePSFB, FMTAFB, r.SenderSSRC, 0, 8+4*len(r.SSRCs))
+	if err != nil {
+		return nil, err
+	}
+
+	fci := buffer[feedbackHeaderSize:]
+	copy(fci, rembID)
+	fci[4] = byte(len(r.SSRCs))
+	fci[5] = byte(exp<<2) | byte(mantissa>>16)
+	binary.BigEndian.PutUint16(fci[6:], uint16(mantissa))
+	for i, ssrc := range r.SSRCs {
+		binary.BigEndian.PutUint32(fci[8+4*i:], ssrc)
+	}
+
+	return buffer, nil
+}
+
+func (r *ReceiverEstimatedMaxBitrate) Unmarshal(buffer []byte) error {
+	return unmarshalRTCP(buffer, r)
+}
+
+func (r *ReceiverEstimatedMaxBitrate) unmarshalPacket(p *RTCPPacket) error {
+	sender, _, fci, err := unmarshalFeedback(p, RTCPTypePSFB, FMTAFB)
+	if err != nil {
+		return err
+	}
+	if len(fci) < 8 || !bytes.Equal(fci[:4], rembID) {
+		return errors.New("rtcp: application layer feedback is not a REMB")
+	}
+
+	num := int(fci[4])
+	if len(fci) < 8+4*num {
+		return errors.New("rtcp: REMB SSRC list larger than packet")
+	}
+
+	exp := uint(fci[5] >> 2)
+	mantissa := uint64(fci[5]&0x03)<<16 | uint64(binary.BigEndian.Uint16(fci[6:]))
+
+	ssrcs := make([]uint32, num)
+	for i := range ssrcs {
+		ssrcs[i] = binary.BigEndian.Uint32(fci[8+4*i:])
+	}
+
+	r.SenderSSRC = sender
+	r.Bitrate = mantissa << exp
+	r.SSRCs = ssrcs
+
+	return nil
+}
+
+// isREMB reports if an application layer feedback packet is a REMB
+func isREMB(p *RTCPPacket) bool {
+	payload := p.GetPayload()
+	return len(payload) >= 8 && bytes.Equal(payload[4:8], rembID)
+}
@@ -0,0 +1,172 @@
+package rtp
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestNackPairs(t *testing.T) {
+	pairs := NackPairsFromSeqs([]uint16{65534, 65535, 0, 16, 17, 100})
+
+	assertEqual(t, len(pairs), 3)
+	assertEqual(t, pairs[0], NackPair{PacketID: 65534, LostPackets: 0x0003})
+	assertEqual(t, pairs[1], NackPair{PacketID: 16, LostPackets: 0x0001})
+	assertEqual(t, pairs[2], NackPair{PacketID: 100})
+
+	seqs := pairs[0].PacketList()
+	assertEqual(t, len(seqs), 3)
+	assertEqual(t, seqs[2], uint16(0))
+}
+
+func TestTransportLayerNack(t *testing.T) {
+	nack := &TransportLayerNack{
+		SenderSSRC: 0x11223344,
+		MediaSSRC:  0x55667788,
+		Nacks:      []NackPair{{PacketID: 0x1234, LostPackets: 0x8001}},
+	}
+
+	data, err := nack.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	golden, _ := hex.DecodeString("81cd0003" + "11223344" + "55667788" + "12348001")
+	compareByteArrays(t, data, golden)
+
+	var nack2 TransportLayerNack
+	err = nack2.Unmarshal(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, nack2.SenderSSRC, uint32(0x11223344))
+	assertEqual(t, nack2.MediaSSRC, uint32(0x55667788))
+	assertEqual(t, len(nack2.Nacks), 1)
+	assertEqual(t, nack2.Nacks[0], nack.Nacks[0])
+}
+
+func TestPictureLossIndication(t *testing.T) {
+	pli := &PictureLossIndication{SenderSSRC: 1, MediaSSRC: 2}
+
+	data, err := pli.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	golden, _ := hex.DecodeString("81ce0002" + "00000001" + "00000002")
+	compareByteArrays(t, data, golden)
+
+	var pli2 PictureLossIndication
+	err = pli2.Unmarshal(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, pli2, *pli)
+
+	var fir FullIntraRequest
+	err = fir.Unmarshal(data)
+	if err == nil {
+		t.Errorf("PLI unmarshaled as FIR")
+	}
+}
+
+func TestFullIntraRequest(t *testing.T) {
+	fir := &FullIntraRequest{
+		SenderSSRC: 1,
+		FIR:        []FIREntry{{SSRC: 0x55667788, SeqNr: 7}, {SSRC: 3, SeqNr: 255}},
+	}
+
+	data, err := fir.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	golden, _ := hex.DecodeString("84ce0006" + "00000001" + "00000000" +
+		"55667788" + "07000000" + "00000003" + "ff000000")
+	compareByteArrays(t, data, golden)
+
+	var fir2 FullIntraRequest
+	err = fir2.Unmarshal(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(fir2.FIR), 2)
+	assertEqual(t, fir2.FIR[1], FIREntry{SSRC: 3, SeqNr: 255})
+}
+
+func TestREMB(t *testing.T) {
+	remb := &ReceiverEstimatedMaxBitrate{
+		SenderSSRC: 1,
+		Bitrate:    8927168,
+		SSRCs:      []uint32{0x11223344, 0x55667788},
+	}
+
+	data, err := remb.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	// 8927168 = 0x220df << 6
+	golden, _ := hex.DecodeString("8fce0006" + "00000001" + "00000000" +
+		"52454d42" + "021a20df" + "11223344" + "55667788")
+	compareByteArrays(t, data, golden)
+
+	var remb2 ReceiverEstimatedMaxBitrate
+	err = remb2.Unmarshal(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, remb2.Bitrate, uint64(8927168))
+	assertEqual(t, len(remb2.SSRCs), 2)
+	assertEqual(t, remb2.SSRCs[1], uint32(0x55667788))
+
+	// precision is lost for large bitrates
+	remb.Bitrate = 1<<40 + 1
+	data, _ = remb.Marshal()
+	remb2.Unmarshal(data)
+	assertEqual(t, remb2.Bitrate, uint64(1<<40))
+
+	// the largest bitrate needs an exponent of 46, well inside the 6 bits
+	remb.Bitrate = 1<<64 - 1
+	data, err = remb.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	remb2.Unmarshal(data)
+	assertEqual(t, remb2.Bitrate, uint64(1<<18-1)<<46)
+}
+
+func TestFeedbackCompound(t *testing.T) {
+	rr := &ReceiverReport{SSRC: 1}
+	sdes := &SourceDescription{Chunks: []SDESChunk{{SSRC: 1, Items: []SDESItem{{Type: SDESCNAME, Text: "a"}}}}}
+	nack := &TransportLayerNack{SenderSSRC: 1, MediaSSRC: 2, Nacks: NackPairsFromSeqs([]uint16{5, 6})}
+	pli := &PictureLossIndication{SenderSSRC: 1, MediaSSRC: 2}
+	fir := &FullIntraRequest{SenderSSRC: 1, FIR: []FIREntry{{SSRC: 2, SeqNr: 1}}}
+	remb := &ReceiverEstimatedMaxBitrate{SenderSSRC: 1, Bitrate: 1000000, SSRCs: []uint32{2}}
+
+	data, err := MarshalRTCPCompound([]RTCPMessage{rr, sdes, nack, pli, fir, remb})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	p, err := NewRTCPCompoundPacket(data, 0)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	msgs, err := p.GetMessages()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	assertEqual(t, len(msgs), 6)
+	if _, ok := msgs[2].(*TransportLayerNack); !ok {
+		t.Errorf("NACK not parsed")
+	}
+	if _, ok := msgs[3].(*PictureLossIndication); !ok {
+		t.Errorf("PLI not parsed")
+	}
+	if _, ok := msgs[4].(*FullIntraRequest); !ok {
+		t.Errorf("FIR not parsed")
+	}
+	if m, ok := msgs[5].(*ReceiverEstimatedMaxBitrate); !ok {
+		t.Errorf("REMB not parsed")
+	} else {
+		assertEqual(t, m.Bitrate, uint64(1000000))
+	}
+}
@@ -2,10 +2,11 @@ package rtp
 
 
 import (
-  "crypto/aes"
   "crypto/cipher"
+  "crypto/hmac"
   "encoding/binary"
   "errors"
+  "hash"
 )
 
 type RTCPTypeClass uint8
@@ -19,8 +20,34 @@ const (
   RTCPTypeAPP        RTCPTypeClass = 204
 )
 
+// https://tools.ietf.org/html/rfc4585#section-6.1
+const (
+  RTCPTypeRTPFB      RTCPTypeClass = 205
+  RTCPTypePSFB       RTCPTypeClass = 206
+)
+
+// https://tools.ietf.org/html/rfc3611#section-2
+const (
+  RTCPTypeXR         RTCPTypeClass = 207
+)
+
 const (
   rtcpHeaderSize = 8
+  rtcpVersion = 2
+
+  // SRTCP always uses a 80 bit tag with AES-CM, see
+  // https://tools.ietf.org/html/rfc3711#section-3.4
+  srtcpCMTagLen = 10
+)
+
+// Errors returned when walking a compound RTCP packet
+var (
+  ErrRTCPTooShort    = errors.New("rtcp: packet too short for RTCP header")
+  ErrRTCPVersion     = errors.New("rtcp: invalid RTCP version")
+  ErrRTCPBadLength   = errors.New("rtcp: packet length larger than compound packet")
+  ErrRTCPBadPadding  = errors.New("rtcp: invalid padding")
+  ErrRTCPFirstPacket = errors.New("rtcp: compound packet does not start with SR or RR")
+  ErrRTCPNoCNAME     = errors.New("rtcp: compound packet has no SDES CNAME")
 )
 
 type RTCPHeader struct {
@@ -36,6 +63,30 @@ func (p *RTCPHeader) Clone() *RTCPHeader {
 	return p2
 }
 
+func (p *RTCPHeader) GetVersion() int {
+  return int(p.buffer[0] >> 6)
+}
+
+func (p *RTCPHeader) GetPadding() bool {
+  return (p.buffer[0] & 0x20) > 0
+}
+
+func (p *RTCPHeader) SetPadding(padding bool) {
+  if padding {
+    p.buffer[0] |= 0x20
+  } else {
+    p.buffer[0] &^= 0x20
+  }
+}
+
+func (p *RTCPHeader) SetRC(rc int) error {
+  if (rc < 0) || (rc > 31) {
+    return errors.New("rtcp: invalid RC value")
+  }
+  p.buffer[0] = (p.buffer[0] & 0xE0) | byte(rc)
+  return nil
+}
+
 func (p *RTCPHeader) GetRC() int {
   rc := p.buffer[0] & 31   // 31 = 0b00011111
   return int(rc);
@@ -66,15 +117,270 @@ func (p* RTCPHeader) SetSenderSSRC(ssrc uint32) {
 }
 
 func (p* RTCPHeader) GetSenderSSRC() uint32 {
+  if len(p.buffer) < rtcpHeaderSize {
+    // empty SDES and BYE packets are only 4 bytes long
+    return 0
+  }
   return binary.BigEndian.Uint32(p.buffer[4:])
 }
 
+// RTCPMessage is a single RTCP packet from a compound packet
+type RTCPMessage interface {
+  GetPT() RTCPTypeClass
+  Marshal() ([]byte, error)
+}
 
 type RTCPPacket struct {
     header RTCPHeader
     payload []byte
 }
 
+func (p *RTCPPacket) GetHeader() *RTCPHeader {
+  return &p.header
+}
+
+func (p *RTCPPacket) GetPT() RTCPTypeClass {
+  return p.header.GetPT()
+}
+
+// GetPayload returns the packet after the sender SSRC with any padding
+// removed
+func (p *RTCPPacket) GetPayload() []byte {
+  return p.payload
+}
+
+func (p *RTCPPacket) Marshal() ([]byte, error) {
+  buffer := make([]byte, 0, len(p.header.buffer)+len(p.payload))
+  buffer = append(buffer, p.header.buffer...)
+  buffer = append(buffer, p.payload...)
+  return buffer, nil
+}
+
+// rtcpUnmarshaler is implemented by typed messages that are decoded from a
+// RTCPPacket
+type rtcpUnmarshaler interface {
+  unmarshalPacket(p *RTCPPacket) error
+}
+
+// unmarshalRTCP decodes a single RTCP packet in buffer into m
+func unmarshalRTCP(buffer []byte, m rtcpUnmarshaler) error {
+  packets, err := splitRTCPPackets(buffer)
+  if err != nil {
+    return err
+  }
+  if len(packets) != 1 {
+    return errors.New("rtcp: expected a single RTCP packet")
+  }
+
+  return m.unmarshalPacket(packets[0])
+}
+
+// newRTCPHeader fills in the header at the start of buffer for a packet that
+// is the full length of buffer, which must be a multiple of 32 bits
+func newRTCPHeader(buffer []byte, pt RTCPTypeClass, rc int) (RTCPHeader, error) {
+  if len(buffer) < 4 {
+    return RTCPHeader{}, ErrRTCPTooShort
+  }
+
+  h := RTCPHeader{buffer: buffer[:4]}
+  if len(buffer) >= rtcpHeaderSize {
+    h.buffer = buffer[:rtcpHeaderSize]
+  }
+
+  if len(buffer)%4 != 0 {
+    return h, errors.New("rtcp: packet must be 32 bit padded")
+  }
+  if len(buffer)/4-1 > 0xFFFF {
+    return h, errors.New("rtcp: packet too long")
+  }
+
+  h.buffer[0] = rtcpVersion << 6
+  err := h.SetRC(rc)
+  if err != nil {
+    return h, err
+  }
+  h.SetPT(pt)
+  h.SetLength(uint16(len(buffer)/4 - 1))
+
+  return h, nil
+}
+
+// parseRTCPMessage turns a RTCP packet into a typed message. Packet types
+// without a typed message are returned as the RTCPPacket.
+func parseRTCPMessage(p *RTCPPacket) (RTCPMessage, error) {
+  var m interface {
+    RTCPMessage
+    rtcpUnmarshaler
+  }
+
+  switch p.GetPT() {
+  case RTCPTypeSR:
+    m = new(SenderReport)
+  case RTCPTypeRR:
+    m = new(ReceiverReport)
+  case RTCPTypeSDES:
+    m = new(SourceDescription)
+  case RTCPTypeBYE:
+    m = new(Goodbye)
+  case RTCPTypeAPP:
+    m = new(ApplicationDefined)
+  case RTCPTypeRTPFB:
+    switch p.header.GetRC() {
+    case FMTGenericNACK:
+      m = new(TransportLayerNack)
+    case FMTTransportCC:
+      m = new(TransportCCFeedback)
+    default:
+      return p, nil
+    }
+  case RTCPTypePSFB:
+    switch {
+    case p.header.GetRC() == FMTPLI:
+      m = new(PictureLossIndication)
+    case p.header.GetRC() == FMTFIR:
+      m = new(FullIntraRequest)
+    case p.header.GetRC() == FMTAFB && isREMB(p):
+      m = new(ReceiverEstimatedMaxBitrate)
+    default:
+      return p, nil
+    }
+  case RTCPTypeXR:
+    m = new(ExtendedReport)
+  default:
+    return p, nil
+  }
+
+  err := m.unmarshalPacket(p)
+  if err != nil {
+    return nil, err
+  }
+
+  return m, nil
+}
+
+// parseRTCPPackets walks a compound RTCP packet and checks it starts with a
+// SR or RR
+func parseRTCPPackets(buffer []byte) ([]*RTCPPacket, error) {
+  packets, err := splitRTCPPackets(buffer)
+  if err != nil {
+    return nil, err
+  }
+
+  pt := packets[0].header.GetPT()
+  if pt != RTCPTypeSR && pt != RTCPTypeRR {
+    return nil, ErrRTCPFirstPacket
+  }
+
+  return packets, nil
+}
+
+// splitRTCPPackets walks a compound RTCP packet using the length field of
+// each header and checks the version, following
+// https://tools.ietf.org/html/rfc3550#appendix-A.2. Padding is removed from
+// any packet, not just the last, since transport cc feedback is padded on
+// its own wherever it is in the compound packet.
+func splitRTCPPackets(buffer []byte) ([]*RTCPPacket, error) {
+  var packets []*RTCPPacket
+
+  for offset := 0; offset < len(buffer); {
+    if len(buffer)-offset < 4 {
+      return nil, ErrRTCPTooShort
+    }
+
+    p := new(RTCPPacket)
+    p.header.buffer = buffer[offset:offset+4]
+
+    if p.header.GetVersion() != rtcpVersion {
+      return nil, ErrRTCPVersion
+    }
+
+    end := offset + p.header.GetLengthInBytes()
+    if end > len(buffer) {
+      return nil, ErrRTCPBadLength
+    }
+
+    if end-offset >= rtcpHeaderSize {
+      p.header.buffer = buffer[offset:offset+rtcpHeaderSize]
+    }
+    payloadStart := offset + len(p.header.buffer)
+    payloadEnd := end
+
+    if p.header.GetPadding() {
+      pad := int(buffer[end-1])
+      if pad == 0 || pad > payloadEnd-payloadStart {
+        return nil, ErrRTCPBadPadding
+      }
+      payloadEnd -= pad
+    }
+
+    p.payload = buffer[payloadStart:payloadEnd]
+    packets = append(packets, p)
+
+    offset = end
+  }
+
+  if len(packets) == 0 {
+    return nil, ErrRTCPTooShort
+  }
+
+  return packets, nil
+}
+
+// MarshalRTCPCompound forms a compound RTCP packet from msgs, which must
+// start with a SR or RR and contain a SDES CNAME as required by
+// https://tools.ietf.org/html/rfc3550#section-6.1
+func MarshalRTCPCompound(msgs []RTCPMessage) ([]byte, error) {
+  if len(msgs) == 0 {
+    return nil, ErrRTCPTooShort
+  }
+
+  pt := msgs[0].GetPT()
+  if pt != RTCPTypeSR && pt != RTCPTypeRR {
+    return nil, ErrRTCPFirstPacket
+  }
+
+  cname := false
+  for _, m := range msgs {
+    if sdes, ok := m.(*SourceDescription); ok && sdes.hasCNAME() {
+      cname = true
+    }
+  }
+  if !cname {
+    return nil, ErrRTCPNoCNAME
+  }
+
+  var buffer []byte
+  for _, m := range msgs {
+    data, err := m.Marshal()
+    if err != nil {
+      return nil, err
+    }
+    buffer = append(buffer, data...)
+  }
+
+  return buffer, nil
+}
+
+// MarshalRTCPReducedSize forms a reduced-size RTCP packet from msgs. Unlike
+// a compound packet it need not start with a SR or RR nor carry a CNAME, see
+// https://tools.ietf.org/html/rfc5506#section-3.1
+func MarshalRTCPReducedSize(msgs []RTCPMessage) ([]byte, error) {
+  if len(msgs) == 0 {
+    return nil, ErrRTCPTooShort
+  }
+
+  var buffer []byte
+  for _, m := range msgs {
+    data, err := m.Marshal()
+    if err != nil {
+      return nil, err
+    }
+    buffer = append(buffer, data...)
+  }
+
+  return buffer, nil
+}
+
 // https://tools.ietf.org/html/rfc7714#section-9.2
 /*
     0                   1                   2                   3
@@ -112,6 +418,12 @@ type RTCPCompoundPacket struct {
   header RTCPHeader
   buffer []byte
   appendix []byte
+  reducedSize bool
+
+  // scratch space for the AES-GCM IV and AAD, and for AES-CM
+  iv [gcmIVSize]byte
+  aad [rtcpHeaderSize+4]byte
+  cm cmScratch
 }
 
 func (p *RTCPCompoundPacket) Clone() *RTCPCompoundPacket {
@@ -121,6 +433,7 @@ func (p *RTCPCompoundPacket) Clone() *RTCPCompoundPacket {
     },
     buffer:    make([]byte, len(p.buffer)),
     appendix:    make([]byte, len(p.appendix)),
+    reducedSize: p.reducedSize,
   }
 
   copy(p2.header.buffer, p.header.buffer)
@@ -129,13 +442,46 @@ func (p *RTCPCompoundPacket) Clone() *RTCPCompoundPacket {
   return p2
 }
 
-func (p *RTCPCompoundPacket) GetPackets() []*RTCPPacket {
-  // TODO Support more than one package
-  rtcpPacket := new(RTCPPacket)
-  rtcpPacket.header = p.header
-  rtcpPacket.payload = p.buffer
+// SetReducedSize allows the packet to be a reduced-size RTCP packet that
+// does not start with a SR or RR as defined in
+// https://tools.ietf.org/html/rfc5506
+func (p *RTCPCompoundPacket) SetReducedSize(rsize bool) {
+  p.reducedSize = rsize
+}
+
+func (p *RTCPCompoundPacket) GetReducedSize() bool {
+  return p.reducedSize
+}
+
+// GetPackets splits the decrypted compound packet into its RTCP packets
+func (p *RTCPCompoundPacket) GetPackets() ([]*RTCPPacket, error) {
+  buffer := make([]byte, 0, len(p.header.buffer)+len(p.buffer))
+  buffer = append(buffer, p.header.buffer...)
+  buffer = append(buffer, p.buffer...)
+
+  if p.reducedSize {
+    return splitRTCPPackets(buffer)
+  }
+  return parseRTCPPackets(buffer)
+}
+
+// GetMessages splits the decrypted compound packet into typed RTCP messages
+func (p *RTCPCompoundPacket) GetMessages() ([]RTCPMessage, error) {
+  packets, err := p.GetPackets()
+  if err != nil {
+    return nil, err
+  }
+
+  msgs := make([]RTCPMessage, 0, len(packets))
+  for _, pkt := range packets {
+    m, err := parseRTCPMessage(pkt)
+    if err != nil {
+      return nil, err
+    }
+    msgs = append(msgs, m)
+  }
 
-  return []*RTCPPacket{rtcpPacket}
+  return msgs, nil
 }
 
 func (p *RTCPCompoundPacket) GetHeader() *RTCPHeader {
@@ -158,13 +504,9 @@ func (p *RTCPCompoundPacket) GetE() bool {
 }
 
 func (p *RTCPCompoundPacket) getAAD() []byte {
-  srtcpIndexLine := make([]byte, len(p.GetESRTCPWord()))
-  copy(srtcpIndexLine, p.GetESRTCPWord())
-
-  headerBuffer := make([]byte, len(p.header.buffer))
-  copy(headerBuffer, p.header.buffer)
-
-  return append(headerBuffer, p.GetESRTCPWord()...)
+  n := copy(p.aad[:], p.header.buffer)
+  copy(p.aad[n:], p.GetESRTCPWord())
+  return p.aad[:]
 }
 
 // https://tools.ietf.org/html/rfc7714#section-9.1
@@ -184,23 +526,14 @@ func (p *RTCPCompoundPacket) getAAD() []byte {
 */
 
 func (p* RTCPCompoundPacket) gcmIV(salt []byte) []byte {
-  srtcpIndex := make([]byte, 4)
-  binary.BigEndian.PutUint32(srtcpIndex, p.GetSRTCPIndex())
+  iv := p.iv[:]
 
-  ssrc := make([]byte, 4)
-  binary.BigEndian.PutUint32(ssrc, p.header.GetSenderSSRC())
-
-  iv := make([]byte, 12)
-
-  iv[2] = ssrc[0]
-  iv[3] = ssrc[1]
-  iv[4] = ssrc[2]
-  iv[5] = ssrc[3]
-
-  iv[8] = srtcpIndex[0]
-  iv[9] = srtcpIndex[1]
-  iv[10] = srtcpIndex[2]
-  iv[11] = srtcpIndex[3]
+  iv[0] = 0
+  iv[1] = 0
+  binary.BigEndian.PutUint32(iv[2:], p.header.GetSenderSSRC())
+  iv[6] = 0
+  iv[7] = 0
+  binary.BigEndian.PutUint32(iv[8:], p.GetSRTCPIndex())
 
   for i := range iv {
     iv[i] ^= salt[i]
@@ -209,27 +542,45 @@ func (p* RTCPCompoundPacket) gcmIV(salt []byte) []byte {
   return iv
 }
 
+// DecryptGCM decrypts the packet with a new AEAD for key. Use DecryptAEAD
+// to avoid expanding the key for every packet.
 func (p *RTCPCompoundPacket) DecryptGCM(key, salt []byte) error {
-  if !p.GetE() {
-    return errors.New("srtcp: Encryption flag not set")
-  }
-
-  block, err := aes.NewCipher(key)
+  gcm, err := newGCM(key)
   if err != nil {
     return err
   }
 
-  gcm, err := cipher.NewGCM(block)
+  return p.DecryptAEAD(gcm, salt)
+}
+
+// EncryptGCM encrypts the packet with a new AEAD for key. Use EncryptAEAD
+// to avoid expanding the key for every packet.
+func (p *RTCPCompoundPacket) EncryptGCM(key, salt []byte) error {
+  gcm, err := newGCM(key)
   if err != nil {
     return err
   }
 
+  return p.EncryptAEAD(gcm, salt)
+}
+
+// DecryptAEAD checks the tag, decrypts the packet in place and removes the
+// tag
+func (p *RTCPCompoundPacket) DecryptAEAD(gcm cipher.AEAD, salt []byte) error {
+  if !p.GetE() {
+    return errors.New("srtcp: Encryption flag not set")
+  }
+
+  if len(p.buffer) < gcm.Overhead() {
+    return errors.New("srtcp: packet too short for auth tag")
+  }
+
   iv := p.gcmIV(salt)
 
   aad := p.getAAD()
   ct := p.buffer
 
-  _, err = gcm.Open(p.buffer[0:0], iv, ct, aad)
+  _, err := gcm.Open(p.buffer[0:0], iv, ct, aad)
   if err != nil {
     return err
   }
@@ -239,27 +590,80 @@ func (p *RTCPCompoundPacket) DecryptGCM(key, salt []byte) error {
   return nil
 }
 
-func (p *RTCPCompoundPacket) EncryptGCM(key, salt []byte) error {
-  block, err := aes.NewCipher(key)
+// EncryptAEAD encrypts the packet in place and appends the tag. It does not
+// allocate when the packet buffer has room for the tag.
+func (p *RTCPCompoundPacket) EncryptAEAD(gcm cipher.AEAD, salt []byte) error {
+  iv := p.gcmIV(salt)
+
+  ptLen := len(p.buffer)
+  p.buffer = append(p.buffer, make([]byte, gcm.Overhead())...)
+
+  aad := p.getAAD()
+  pt := p.buffer[:ptLen]
+
+  gcm.Seal(p.buffer[0:0], iv, pt, aad)
+
+  return nil
+}
+
+// EncryptCM encrypts the packet with AES counter mode, if the E flag is set,
+// and appends a 80 bit HMAC-SHA1 tag after the E flag and SRTCP index. Use
+// EncryptCMBlock to avoid expanding the keys for every packet.
+func (p *RTCPCompoundPacket) EncryptCM(key, salt, authKey []byte) error {
+  block, mac, err := newCM(key, authKey)
   if err != nil {
     return err
   }
 
-  gcm, err := cipher.NewGCM(block)
+  return p.EncryptCMBlock(block, mac, salt)
+}
+
+// DecryptCM checks the tag that follows the E flag and SRTCP index, removes
+// it, and decrypts the packet if the E flag is set. Use DecryptCMBlock to
+// avoid expanding the keys for every packet.
+func (p *RTCPCompoundPacket) DecryptCM(key, salt, authKey []byte) error {
+  block, mac, err := newCM(key, authKey)
   if err != nil {
     return err
   }
 
-  iv := p.gcmIV(salt)
+  return p.DecryptCMBlock(block, mac, salt)
+}
 
-  tag := make([]byte, gcm.Overhead())
-  p.buffer = append(p.buffer, tag...)
+// EncryptCMBlock encrypts the packet in place with block, if the E flag is
+// set, and appends a tag from mac, which is reset first
+func (p *RTCPCompoundPacket) EncryptCMBlock(block cipher.Block, mac hash.Hash, salt []byte) error {
+  if p.GetE() {
+    p.cm.setIV(salt, p.header.GetSenderSSRC(), uint64(p.GetSRTCPIndex()))
+    p.cm.xor(block, p.buffer)
+  }
 
-  aad := p.getAAD()
-  pt := p.buffer[:len(p.buffer)-len(tag)]
+  tag := p.cm.tagOf(mac, srtcpCMTagLen, p.header.buffer, p.buffer, p.GetESRTCPWord())
+  p.appendix = append(p.appendix[:4], tag...)
 
-  gcm.Seal(p.buffer[0:0], iv, pt, aad)
+  return nil
+}
+
+// DecryptCMBlock checks the tag that follows the E flag and SRTCP index with
+// mac, removes it, and decrypts the packet in place with block if the E flag
+// is set
+func (p *RTCPCompoundPacket) DecryptCMBlock(block cipher.Block, mac hash.Hash, salt []byte) error {
+  if len(p.appendix) != 4+srtcpCMTagLen {
+    return errors.New("srtcp: packet too short for auth tag")
+  }
+
+  tag := p.cm.tagOf(mac, srtcpCMTagLen, p.header.buffer, p.buffer, p.GetESRTCPWord())
+  if !hmac.Equal(tag, p.appendix[4:]) {
+    return errors.New("srtcp: authentication failed")
+  }
+  p.appendix = p.appendix[:4]
+
+  if !p.GetE() {
+    return nil
+  }
 
+  p.cm.setIV(salt, p.header.GetSenderSSRC(), uint64(p.GetSRTCPIndex()))
+  p.cm.xor(block, p.buffer)
   return nil
 }
 
@@ -270,15 +674,29 @@ func (p *RTCPCompoundPacket) GetBuffer() []byte {
 }
 
 func NewSRTCPPacket(buffer []byte) (*RTCPCompoundPacket, error) {
+  return newSRTCPPacket(buffer, 0)
+}
+
+// NewSRTCPPacketCM splits a SRTCP packet protected with AES-CM, where the
+// authentication tag follows the E flag and SRTCP index
+func NewSRTCPPacketCM(buffer []byte) (*RTCPCompoundPacket, error) {
+  return newSRTCPPacket(buffer, srtcpCMTagLen)
+}
+
+func newSRTCPPacket(buffer []byte, tagLen int) (*RTCPCompoundPacket, error) {
   sp := new(RTCPCompoundPacket)
 
   if len(buffer) < rtcpHeaderSize {
     return nil, errors.New("rtcp: header size is too small")
   }
 
-  sp.header.buffer = buffer[:rtcpHeaderSize]
-  length := sp.header.GetLengthInBytes() + 12
+  // the E flag and SRTCP index follow the encrypted compound packet and tag
+  if len(buffer) < rtcpHeaderSize+4+tagLen {
+    return nil, errors.New("rtcp: packet too small for SRTCP index")
+  }
+  length := len(buffer) - 4 - tagLen
 
+  sp.header.buffer = buffer[:rtcpHeaderSize]
   sp.buffer = buffer[rtcpHeaderSize:length]
   sp.appendix = buffer[length:]
 
@@ -288,9 +706,15 @@ func NewSRTCPPacket(buffer []byte) (*RTCPCompoundPacket, error) {
 func NewRTCPCompoundPacket(buffer []byte, srtcpIndex uint32) (*RTCPCompoundPacket, error)  {
   p := new(RTCPCompoundPacket)
 
+  // SRTCP needs the sender SSRC from the first header to form the IV
+  if len(buffer) < rtcpHeaderSize {
+    return nil, ErrRTCPTooShort
+  }
+
   p.header.buffer = buffer[:rtcpHeaderSize]
   p.buffer = buffer[rtcpHeaderSize:]
-  p.appendix = make([]byte, 4)
+  // room for the AES-CM tag
+  p.appendix = make([]byte, 4, 4+srtcpCMTagLen)
   // | (1 << 32) sets the E-bit to 1
   binary.BigEndian.PutUint32(p.appendix, srtcpIndex | (1 << 31))
 
@@ -301,6 +725,7 @@ func NewRTCPPacket(pt RTCPTypeClass, len uint16, senderSsrc uint32, payload []by
   p := new(RTCPPacket)
 
   p.header.buffer = make([]byte, rtcpHeaderSize, MTU)
+  p.header.buffer[0] = rtcpVersion << 6
   p.payload = payload
 
   p.header.SetPT(pt)
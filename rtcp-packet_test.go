@@ -102,3 +102,325 @@ func TestSRTCPEncryption(t *testing.T) {
 
   compareByteArrays(t, p.GetBuffer(), ciphertext)
 }
+
+// SR with no report blocks, RR with no report blocks, and SDES with a CNAME
+var compoundHex = "80c80006bcdc0094" + "0102030405060708" + "0a0b0c0d" + "00000001" + "00000002" +
+  "80c9000111223344" +
+  "81ca0003bcdc0094" + "0103616263000000"
+
+func TestRTCPGetPackets(t *testing.T) {
+  compound, _ := hex.DecodeString(compoundHex)
+
+  p, err := NewRTCPCompoundPacket(compound, 1)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+
+  packets, err := p.GetPackets()
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+
+  assertEqual(t, len(packets), 3)
+  assertEqual(t, packets[0].GetPT(), RTCPTypeSR)
+  assertEqual(t, packets[0].header.GetSenderSSRC(), uint32(0xbcdc0094))
+  assertEqual(t, len(packets[0].GetPayload()), 20)
+  assertEqual(t, packets[1].GetPT(), RTCPTypeRR)
+  assertEqual(t, packets[1].header.GetSenderSSRC(), uint32(0x11223344))
+  assertEqual(t, len(packets[1].GetPayload()), 0)
+  assertEqual(t, packets[2].GetPT(), RTCPTypeSDES)
+  assertEqual(t, packets[2].header.GetRC(), 1)
+  compareByteArrays(t, packets[2].GetPayload(), []byte{1, 3, 'a', 'b', 'c', 0, 0, 0})
+
+  msgs, err := p.GetMessages()
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  data, err := MarshalRTCPCompound(msgs)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  compareByteArrays(t, data, compound)
+}
+
+func TestRTCPGetPacketsInvalid(t *testing.T) {
+  compound, _ := hex.DecodeString(compoundHex)
+
+  bad := []struct {
+    name string
+    data []byte
+    err  error
+  }{
+    {"truncated", compound[:len(compound)-4], ErrRTCPBadLength},
+    {"trailing", append(append([]byte{}, compound...), 0x80, 0xc9), ErrRTCPTooShort},
+    {"version", append([]byte{0x40}, compound[1:]...), ErrRTCPVersion},
+    {"first type", compound[36:], ErrRTCPFirstPacket},
+  }
+
+  for _, tc := range bad {
+    _, err := parseRTCPPackets(tc.data)
+    if err != tc.err {
+      t.Errorf("%s: expected %v got %v", tc.name, tc.err, err)
+    }
+  }
+
+  // padding on the last packet is removed
+  padded := append([]byte{}, compound[:36]...)
+  padded = append(padded, 0xa1, 0xca, 0x00, 0x03, 0xbc, 0xdc, 0x00, 0x94, 0x01, 0x01, 'a', 0, 0, 0, 0, 3)
+  packets, err := parseRTCPPackets(padded)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  compareByteArrays(t, packets[2].GetPayload(), []byte{1, 1, 'a', 0, 0})
+
+  // and so is padding on a packet before the last
+  middle := append(append([]byte{}, padded...), 0x80, 0xcb, 0x00, 0x00)
+  packets, err = parseRTCPPackets(middle)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  assertEqual(t, len(packets), 4)
+  compareByteArrays(t, packets[2].GetPayload(), []byte{1, 1, 'a', 0, 0})
+
+  padded[len(padded)-1] = 9
+  _, err = parseRTCPPackets(padded)
+  assertEqual(t, err, ErrRTCPBadPadding)
+}
+
+func TestRTCPCompoundEncryptDecrypt(t *testing.T) {
+  compound, _ := hex.DecodeString(compoundHex)
+
+  p, err := NewRTCPCompoundPacket(append([]byte{}, compound...), 7)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  err = p.EncryptGCM(key[:16], salt)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+
+  sp, err := NewSRTCPPacket(p.GetBuffer())
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  assertEqual(t, sp.GetSRTCPIndex(), uint32(7))
+
+  err = sp.DecryptGCM(key[:16], salt)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+
+  packets, err := sp.GetPackets()
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  assertEqual(t, len(packets), 3)
+  assertEqual(t, packets[1].GetPT(), RTCPTypeRR)
+}
+
+func TestRTCPCompoundAEAD(t *testing.T) {
+  compound, _ := hex.DecodeString(compoundHex)
+  gcm, err := newGCM(key[:16])
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+
+  expected, _ := NewRTCPCompoundPacket(append([]byte{}, compound...), 7)
+  err = expected.EncryptGCM(key[:16], salt)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+
+  buffer := make([]byte, len(compound), MTU)
+  copy(buffer, compound)
+  p, _ := NewRTCPCompoundPacket(buffer, 7)
+  err = p.EncryptAEAD(gcm, salt)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  compareByteArrays(t, p.GetBuffer(), expected.GetBuffer())
+
+  err = p.DecryptAEAD(gcm, salt)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  compareByteArrays(t, buffer[:len(compound)], compound)
+
+  // a packet with room for the tag is processed in place
+  allocs := testing.AllocsPerRun(100, func() {
+    p.EncryptAEAD(gcm, salt)
+    p.DecryptAEAD(gcm, salt)
+  })
+  assertEqual(t, allocs, float64(0))
+}
+
+func BenchmarkSRTCPEncryptDecryptGCM(b *testing.B) {
+  compound, _ := hex.DecodeString(compoundHex)
+  buffer := make([]byte, len(compound), MTU)
+  copy(buffer, compound)
+  p, _ := NewRTCPCompoundPacket(buffer, 7)
+
+  b.ReportAllocs()
+  for i := 0; i < b.N; i++ {
+    p.EncryptGCM(key[:16], salt)
+    p.DecryptGCM(key[:16], salt)
+  }
+}
+
+func BenchmarkSRTCPEncryptDecryptAEAD(b *testing.B) {
+  compound, _ := hex.DecodeString(compoundHex)
+  buffer := make([]byte, len(compound), MTU)
+  copy(buffer, compound)
+  p, _ := NewRTCPCompoundPacket(buffer, 7)
+  gcm, _ := newGCM(key[:16])
+
+  b.ReportAllocs()
+  for i := 0; i < b.N; i++ {
+    p.EncryptAEAD(gcm, salt)
+    p.DecryptAEAD(gcm, salt)
+  }
+}
+
+func TestRTCPReducedSize(t *testing.T) {
+  pli := &PictureLossIndication{SenderSSRC: 0xbcdc0094, MediaSSRC: 0x11223344}
+  nack := &TransportLayerNack{SenderSSRC: 0xbcdc0094, MediaSSRC: 0x11223344,
+    Nacks: NackPairsFromSeqs([]uint16{10, 12})}
+
+  _, err := MarshalRTCPCompound([]RTCPMessage{pli})
+  assertEqual(t, err, ErrRTCPFirstPacket)
+
+  _, err = MarshalRTCPReducedSize(nil)
+  assertEqual(t, err, ErrRTCPTooShort)
+
+  data, err := MarshalRTCPReducedSize([]RTCPMessage{pli, nack})
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+
+  p, err := NewRTCPCompoundPacket(data, 1)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  _, err = p.GetPackets()
+  assertEqual(t, err, ErrRTCPFirstPacket)
+
+  p.SetReducedSize(true)
+  msgs, err := p.GetMessages()
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  assertEqual(t, len(msgs), 2)
+  assertEqual(t, *msgs[0].(*PictureLossIndication), *pli)
+  assertEqual(t, len(msgs[1].(*TransportLayerNack).Nacks[0].PacketList()), 2)
+
+  _, err = NewRTCPCompoundPacket(data[:4], 1)
+  assertEqual(t, err, ErrRTCPTooShort)
+}
+
+// Test vector from libsrtp's srtp_validate
+func TestSRTCPEncryptCM(t *testing.T) {
+  masterKey, _ := hex.DecodeString("e1f97a0d3e018be0d64fa32c06de4139")
+  masterSalt, _ := hex.DecodeString("0ec675ad498afeebb6960b3aabe6")
+  plain, _ := hex.DecodeString("81c8000bcafebabeabababababababababababababababab")
+  golden, _ := hex.DecodeString("81c8000bcafebabe7128035be487b9bdbef89041f977a5a880000001993e08cd54d6c1230798")
+
+  kdf, _ := NewKDF(masterKey, masterSalt)
+  _, _, key, salt, err := kdf.DeriveForStream(SRTP_AES128_CM_HMAC_SHA1_80)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  _, authKey, _ := kdf.DeriveAuthForStream(SRTP_AES128_CM_HMAC_SHA1_80)
+
+  p, err := NewRTCPCompoundPacket(append([]byte{}, plain...), 1)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  err = p.EncryptCM(key, salt, authKey)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  compareByteArrays(t, p.GetBuffer(), golden)
+
+  sp, err := NewSRTCPPacketCM(append([]byte{}, golden...))
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  assertEqual(t, sp.GetSRTCPIndex(), uint32(1))
+  err = sp.DecryptCM(key, salt, authKey)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  compareByteArrays(t, sp.GetBuffer(), append(plain, 0x80, 0, 0, 1))
+
+  // a modified index fails authentication
+  golden[len(golden)-11] ^= 1
+  sp, _ = NewSRTCPPacketCM(golden)
+  err = sp.DecryptCM(key, salt, authKey)
+  if err == nil {
+    t.Fatalf("expected authentication failure")
+  }
+}
+
+func TestSRTCPCMBlock(t *testing.T) {
+  compound, _ := hex.DecodeString(compoundHex)
+  authKey := make([]byte, 20)
+  block, mac, err := newCM(key[:16], authKey)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+
+  expected, _ := NewRTCPCompoundPacket(append([]byte{}, compound...), 7)
+  err = expected.EncryptCM(key[:16], salt, authKey)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+
+  buffer := make([]byte, len(compound), MTU)
+  copy(buffer, compound)
+  p, _ := NewRTCPCompoundPacket(buffer, 7)
+  err = p.EncryptCMBlock(block, mac, salt)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  compareByteArrays(t, p.GetBuffer(), expected.GetBuffer())
+
+  err = p.DecryptCMBlock(block, mac, salt)
+  if err != nil {
+    t.Fatalf(err.Error())
+  }
+  compareByteArrays(t, buffer[:len(compound)], compound)
+
+  // the tag fits in the appendix so only the counter mode stream of each
+  // direction is allocated
+  allocs := testing.AllocsPerRun(100, func() {
+    p.EncryptCMBlock(block, mac, salt)
+    p.DecryptCMBlock(block, mac, salt)
+  })
+  if allocs > 2 {
+    t.Errorf("SRTCP AES-CM round trip made %v allocations", allocs)
+  }
+}
+
+func BenchmarkSRTCPEncryptDecryptCM(b *testing.B) {
+  compound, _ := hex.DecodeString(compoundHex)
+  p, _ := NewRTCPCompoundPacket(compound, 7)
+  authKey := make([]byte, 20)
+
+  b.ReportAllocs()
+  for i := 0; i < b.N; i++ {
+    p.EncryptCM(key[:16], salt, authKey)
+    p.DecryptCM(key[:16], salt, authKey)
+  }
+}
+
+func BenchmarkSRTCPEncryptDecryptCMBlock(b *testing.B) {
+  compound, _ := hex.DecodeString(compoundHex)
+  p, _ := NewRTCPCompoundPacket(compound, 7)
+  block, mac, _ := newCM(key[:16], make([]byte, 20))
+
+  b.ReportAllocs()
+  for i := 0; i < b.N; i++ {
+    p.EncryptCMBlock(block, mac, salt)
+    p.DecryptCMBlock(block, mac, salt)
+  }
+}
@@ -0,0 +1,214 @@
+package rtp
+
+/*
+Sender and Receiver Reports are defined in
+https://tools.ietf.org/html/rfc3550#section-6.4
+
+        0                   1                   2                   3
+        0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+       +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+header |V=2|P|    RC   |   PT=SR=200   |             length            |
+       +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+       |                         SSRC of sender                        |
+       +=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+
+sender |              NTP timestamp, most significant word             |
+info   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+       |             NTP timestamp, least significant word             |
+       +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+       |                         RTP timestamp                         |
+       +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+       |                     sender's packet count                     |
+       +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+       |                      sender's octet count                     |
+       +=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+
+report |                 SSRC_1 (SSRC of first source)                 |
+block  +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+  1    | fraction lost |       cumulative number of packets lost       |
+       +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+       |           extended highest sequence number received           |
+       +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+       |                      interarrival jitter                      |
+       +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+       |                         last SR (LSR)                         |
+       +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+       |                   delay since last SR (DLSR)                  |
+       +=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+
+
+A Receiver Report is the same without the sender info.
+*/
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	senderInfoSize  = 20
+	reportBlockSize = 24
+)
+
+// ReportBlock is the reception statistics for one source
+type ReportBlock struct {
+	SSRC           uint32
+	FractionLost   uint8
+	CumulativeLost int32 // 24 bit signed
+	ExtHighestSeq  uint32
+	Jitter         uint32
+	LSR            uint32 // middle 32 bits of the NTP timestamp of the last SR
+	DLSR           uint32 // delay since last SR in 1/65536 seconds
+}
+
+func (r *ReportBlock) marshal(buffer []byte) {
+	lost := r.CumulativeLost
+	if lost > 0x7FFFFF {
+		lost = 0x7FFFFF
+	} else if lost < -0x800000 {
+		lost = -0x800000
+	}
+
+	binary.BigEndian.PutUint32(buffer[0:], r.SSRC)
+	binary.BigEndian.PutUint32(buffer[4:], uint32(r.FractionLost)<<24|uint32(lost)&0xFFFFFF)
+	binary.BigEndian.PutUint32(buffer[8:], r.ExtHighestSeq)
+	binary.BigEndian.PutUint32(buffer[12:], r.Jitter)
+	binary.BigEndian.PutUint32(buffer[16:], r.LSR)
+	binary.BigEndian.PutUint32(buffer[20:], r.DLSR)
+}
+
+func (r *ReportBlock) unmarshal(buffer []byte) {
+	r.SSRC = binary.BigEndian.Uint32(buffer[0:])
+	r.FractionLost = buffer[4]
+	r.CumulativeLost = int32(binary.BigEndian.Uint32(buffer[4:])<<8) >> 8 // sign extend 24 bits
+	r.ExtHighestSeq = binary.BigEndian.Uint32(buffer[8:])
+	r.Jitter = binary.BigEndian.Uint32(buffer[12:])
+	r.LSR = binary.BigEndian.Uint32(buffer[16:])
+	r.DLSR = binary.BigEndian.Uint32(buffer[20:])
+}
+
+func marshalReportBlocks(buffer []byte, reports []ReportBlock) {
+	for i := range reports {
+		reports[i].marshal(buffer[i*reportBlockSize:])
+	}
+}
+
+func unmarshalReportBlocks(payload []byte, rc int) ([]ReportBlock, error) {
+	if len(payload) < rc*reportBlockSize {
+		return nil, errors.New("rtcp: report blocks larger than packet")
+	}
+
+	reports := make([]ReportBlock, rc)
+	for i := range reports {
+		reports[i].unmarshal(payload[i*reportBlockSize:])
+	}
+
+	return reports, nil
+}
+
+// SenderReport is a RTCP SR packet
+type SenderReport struct {
+	SSRC        uint32
+	NTPTime     uint64 // 32.32 fixed point seconds since 1900
+	RTPTime     uint32
+	PacketCount uint32
+	OctetCount  uint32
+	Reports     []ReportBlock
+}
+
+func (r *SenderReport) GetPT() RTCPTypeClass {
+	return RTCPTypeSR
+}
+
+func (r *SenderReport) Marshal() ([]byte, error) {
+	buffer := make([]byte, rtcpHeaderSize+senderInfoSize+len(r.Reports)*reportBlockSize)
+
+	h, err := newRTCPHeader(buffer, RTCPTypeSR, len(r.Reports))
+	if err != nil {
+		return nil, err
+	}
+	h.SetSenderSSRC(r.SSRC)
+
+	info := buffer[rtcpHeaderSize:]
+	binary.BigEndian.PutUint64(info[0:], r.NTPTime)
+	binary.BigEndian.PutUint32(info[8:], r.RTPTime)
+	binary.BigEndian.PutUint32(info[12:], r.PacketCount)
+	binary.BigEndian.PutUint32(info[16:], r.OctetCount)
+
+	marshalReportBlocks(info[senderInfoSize:], r.Reports)
+
+	return buffer, nil
+}
+
+func (r *SenderReport) Unmarshal(buffer []byte) error {
+	return unmarshalRTCP(buffer, r)
+}
+
+func (r *SenderReport) unmarshalPacket(p *RTCPPacket) error {
+	if p.GetPT() != RTCPTypeSR {
+		return errors.New("rtcp: packet is not a SR")
+	}
+
+	info := p.GetPayload()
+	if len(p.header.buffer) < rtcpHeaderSize || len(info) < senderInfoSize {
+		return errors.New("rtcp: SR too short for sender info")
+	}
+
+	reports, err := unmarshalReportBlocks(info[senderInfoSize:], p.header.GetRC())
+	if err != nil {
+		return err
+	}
+
+	r.SSRC = p.header.GetSenderSSRC()
+	r.NTPTime = binary.BigEndian.Uint64(info[0:])
+	r.RTPTime = binary.BigEndian.Uint32(info[8:])
+	r.PacketCount = binary.BigEndian.Uint32(info[12:])
+	r.OctetCount = binary.BigEndian.Uint32(info[16:])
+	r.Reports = reports
+
+	return nil
+}
+
+// ReceiverReport is a RTCP RR packet
+type ReceiverReport struct {
+	SSRC    uint32
+	Reports []ReportBlock
+}
+
+func (r *ReceiverReport) GetPT() RTCPTypeClass {
+	return RTCPTypeRR
+}
+
+func (r *ReceiverReport) Marshal() ([]byte, error) {
+	buffer := make([]byte, rtcpHeaderSize+len(r.Reports)*reportBlockSize)
+
+	h, err := newRTCPHeader(buffer, RTCPTypeRR, len(r.Reports))
+	if err != nil {
+		return nil, err
+	}
+	h.SetSenderSSRC(r.SSRC)
+
+	marshalReportBlocks(buffer[rtcpHeaderSize:], r.Reports)
+
+	return buffer, nil
+}
+
+func (r *ReceiverReport) Unmarshal(buffer []byte) error {
+	return unmarshalRTCP(buffer, r)
+}
+
+func (r *ReceiverReport) unmarshalPacket(p *RTCPPacket) error {
+	if p.GetPT() != RTCPTypeRR {
+		return errors.New("rtcp: packet is not a RR")
+	}
+	if len(p.header.buffer) < rtcpHeaderSize {
+		return errors.New("rtcp: RR too short for SSRC")
+	}
+
+	reports, err := unmarshalReportBlocks(p.GetPayload(), p.header.GetRC())
+	if err != nil {
+		return err
+	}
+
+	r.SSRC = p.header.GetSenderSSRC()
+	r.Reports = reports
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package rtp
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestSenderReport(t *testing.T) {
+	sr := &SenderReport{
+		SSRC:        0xbcdc0094,
+		NTPTime:     0xe2a6f3b7c0000000,
+		RTPTime:     160000,
+		PacketCount: 1000,
+		OctetCount:  160000,
+		Reports: []ReportBlock{
+			{
+				SSRC:           0x11223344,
+				FractionLost:   25,
+				CumulativeLost: -3,
+				ExtHighestSeq:  0x00010005,
+				Jitter:         77,
+				LSR:            0xf3b7c000,
+				DLSR:           0x00018000,
+			},
+		},
+	}
+
+	data, err := sr.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	golden, _ := hex.DecodeString("81c8000cbcdc0094" +
+		"e2a6f3b7c0000000" + "00027100" + "000003e8" + "00027100" +
+		"11223344" + "19fffffd" + "00010005" + "0000004d" + "f3b7c000" + "00018000")
+	compareByteArrays(t, data, golden)
+
+	var sr2 SenderReport
+	err = sr2.Unmarshal(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, sr2.SSRC, sr.SSRC)
+	assertEqual(t, sr2.NTPTime, sr.NTPTime)
+	assertEqual(t, sr2.RTPTime, sr.RTPTime)
+	assertEqual(t, sr2.PacketCount, sr.PacketCount)
+	assertEqual(t, sr2.OctetCount, sr.OctetCount)
+	assertEqual(t, len(sr2.Reports), 1)
+	assertEqual(t, sr2.Reports[0], sr.Reports[0])
+
+	var rr ReceiverReport
+	err = rr.Unmarshal(data)
+	if err == nil {
+		t.Errorf("SR unmarshaled as a RR")
+	}
+}
+
+func TestReceiverReport(t *testing.T) {
+	rr := &ReceiverReport{SSRC: 0x11223344}
+	for i := 0; i < 3; i++ {
+		rr.Reports = append(rr.Reports, ReportBlock{SSRC: uint32(i), CumulativeLost: 0x1000000})
+	}
+
+	data, err := rr.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(data), 8+3*24)
+	assertEqual(t, data[0], byte(0x83))
+
+	var rr2 ReceiverReport
+	err = rr2.Unmarshal(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, rr2.SSRC, uint32(0x11223344))
+	assertEqual(t, len(rr2.Reports), 3)
+	assertEqual(t, rr2.Reports[2].SSRC, uint32(2))
+	// cumulative lost is clamped to 24 bits
+	assertEqual(t, rr2.Reports[2].CumulativeLost, int32(0x7FFFFF))
+
+	// RC larger than the packet
+	data[0] = 0x84
+	err = rr2.Unmarshal(data)
+	if err == nil {
+		t.Errorf("RR with too many report blocks was accepted")
+	}
+}
+
+func TestReportCompound(t *testing.T) {
+	sr := &SenderReport{SSRC: 1, NTPTime: 2, RTPTime: 3}
+	rr := &ReceiverReport{SSRC: 1, Reports: []ReportBlock{{SSRC: 9, FractionLost: 1}}}
+
+	sdes := &SourceDescription{Chunks: []SDESChunk{{SSRC: 1, Items: []SDESItem{{Type: SDESCNAME, Text: "a"}}}}}
+
+	_, err := MarshalRTCPCompound([]RTCPMessage{sr, rr})
+	assertEqual(t, err, ErrRTCPNoCNAME)
+
+	data, err := MarshalRTCPCompound([]RTCPMessage{sr, rr, sdes})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	p, err := NewRTCPCompoundPacket(data, 0)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	msgs, err := p.GetMessages()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	assertEqual(t, len(msgs), 3)
+	sr2, ok := msgs[0].(*SenderReport)
+	if !ok {
+		t.Fatalf("First packet is not a SR")
+	}
+	assertEqual(t, sr2.RTPTime, uint32(3))
+	rr2, ok := msgs[1].(*ReceiverReport)
+	if !ok {
+		t.Fatalf("Second packet is not a RR")
+	}
+	assertEqual(t, rr2.Reports[0], ReportBlock{SSRC: 9, FractionLost: 1})
+}
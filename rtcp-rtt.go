@@ -0,0 +1,158 @@
+package rtp
+
+import (
+	"time"
+)
+
+// sentReportHistory is how many of our SRs and RRTRs are remembered to
+// match against the LSR or LRR of incoming reports
+const sentReportHistory = 32
+
+// rttSmoothing is the weight of a new sample in the smoothed RTT
+const rttSmoothing = 1.0 / 8
+
+// RTTStats is the round trip time to one remote SSRC computed from the
+// LSR and DLSR of its reports as in
+// https://tools.ietf.org/html/rfc3550#section-6.4.1
+type RTTStats struct {
+	SSRC       uint32
+	Latest     time.Duration
+	Smoothed   time.Duration
+	LastUpdate time.Time
+}
+
+// sentReport is a SR or RRTR we formed, keyed by the middle 32 bits of its
+// NTP timestamp
+type sentReport struct {
+	ssrc uint32
+	ntp  uint32
+	sent time.Time
+}
+
+// remoteSR is the last SR received from a remote source
+type remoteSR struct {
+	ntp     uint32
+	arrival time.Time
+}
+
+// fromShortNTP converts a delay in units of 1/65536 seconds to a duration
+func fromShortNTP(d uint32) time.Duration {
+	return time.Duration(uint64(d) * uint64(time.Second) >> 16)
+}
+
+// toShortNTP converts a duration to units of 1/65536 seconds
+func toShortNTP(d time.Duration) uint32 {
+	if d < 0 {
+		return 0
+	}
+	return uint32(uint64(d) << 16 / uint64(time.Second))
+}
+
+// recordSentReport must be called with s.mu held
+func (s *RTPSession) recordSentReport(ssrc uint32, ntp uint64, now time.Time) {
+	if len(s.sentReports) >= sentReportHistory {
+		s.sentReports = s.sentReports[1:]
+	}
+	s.sentReports = append(s.sentReports, sentReport{
+		ssrc: ssrc,
+		ntp:  uint32(ntp >> 16),
+		sent: now,
+	})
+}
+
+// updateRTT matches the LSR of a report about our ssrc against the reports
+// we sent and updates the RTT to remote. It must be called with s.mu held.
+func (s *RTPSession) updateRTT(remote, ssrc, lsr, dlsr uint32, arrival time.Time) {
+	if lsr == 0 {
+		// no SR has been received from us yet
+		return
+	}
+
+	for i := len(s.sentReports) - 1; i >= 0; i-- {
+		sr := s.sentReports[i]
+		if sr.ssrc != ssrc || sr.ntp != lsr {
+			continue
+		}
+
+		rtt := arrival.Sub(sr.sent) - fromShortNTP(dlsr)
+		if rtt < 0 {
+			rtt = 0
+		}
+
+		st, ok := s.rtts[remote]
+		if !ok {
+			st = &RTTStats{SSRC: remote, Smoothed: rtt}
+			s.rtts[remote] = st
+		}
+		st.Latest = rtt
+		st.Smoothed += time.Duration(rttSmoothing * float64(rtt-st.Smoothed))
+		st.LastUpdate = arrival
+		return
+	}
+}
+
+// processRTCP updates the session from the reports in a decoded compound
+// packet that arrived at the time arrival
+func (s *RTPSession) processRTCP(msgs []RTCPMessage, arrival time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range msgs {
+		switch msg := m.(type) {
+		case *SenderReport:
+			s.remoteSRs[msg.SSRC] = remoteSR{
+				ntp:     uint32(msg.NTPTime >> 16),
+				arrival: arrival,
+			}
+			for _, rb := range msg.Reports {
+				s.updateRTT(msg.SSRC, rb.SSRC, rb.LSR, rb.DLSR, arrival)
+			}
+		case *ReceiverReport:
+			for _, rb := range msg.Reports {
+				s.updateRTT(msg.SSRC, rb.SSRC, rb.LSR, rb.DLSR, arrival)
+			}
+		case *ExtendedReport:
+			for _, b := range msg.Blocks {
+				dlrr, ok := b.(*XRDLRRBlock)
+				if !ok {
+					continue
+				}
+				for _, r := range dlrr.Reports {
+					s.updateRTT(msg.SSRC, r.SSRC, r.LRR, r.DLRR, arrival)
+				}
+			}
+		}
+	}
+}
+
+// GetRTT returns the round trip time to the remote ssrc
+func (s *RTPSession) GetRTT(ssrc uint32) (RTTStats, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.rtts[ssrc]
+	if !ok {
+		return RTTStats{}, false
+	}
+	return *st, true
+}
+
+// NewRtcpRRTR forms an Extended Report with a Receiver Reference Time block
+// from ssrc so receivers that do not send SRs can measure RTT from the DLRR
+// blocks sent in reply, see https://tools.ietf.org/html/rfc3611#section-4.4
+func (s *RTPSession) NewRtcpRRTR(ssrc uint32) (*ExtendedReport, error) {
+	ssrc = s.mapLocalSSRC(ssrc)
+	now := s.now()
+	ntp := toNTP(now)
+
+	s.mu.Lock()
+	s.recordSentReport(ssrc, ntp, now)
+	s.mu.Unlock()
+
+	xr := &ExtendedReport{
+		SSRC:   ssrc,
+		Blocks: []XRBlock{&XRReceiverRefTimeBlock{NTPTime: ntp}},
+	}
+
+	return xr, nil
+}
@@ -0,0 +1,145 @@
+package rtp
+
+import (
+	"testing"
+	"time"
+)
+
+func newRTTTestSession(t *testing.T, now *time.Time) *RTPSession {
+	key := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	salt := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+
+	s := NewRTPSession(false)
+	err := s.SetSRTP(SRTP_AEAD_AES_128_GCM, false, key, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	s.SetClockRate(8, 8000)
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+// sendRTCP sends a compound packet of report and a CNAME from one session
+// to another with the given SRTCP index
+func sendRTCP(t *testing.T, from, to *RTPSession, report RTCPMessage, ssrc, index uint32) {
+	sdes := &SourceDescription{Chunks: []SDESChunk{
+		{SSRC: ssrc, Items: []SDESItem{{Type: SDESCNAME, Text: "test"}}},
+	}}
+	data, err := from.MarshalRTCP([]RTCPMessage{report, sdes})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	p, _ := NewRTCPCompoundPacket(data, index)
+	data, err = from.EncodeRTCP(p)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	_, err = to.DecodeRTCP(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+}
+
+func TestShortNTP(t *testing.T) {
+	assertEqual(t, toShortNTP(time.Second), uint32(0x10000))
+	assertEqual(t, toShortNTP(-time.Second), uint32(0))
+	assertEqual(t, fromShortNTP(0x18000), 1500*time.Millisecond)
+}
+
+func TestRTTFromReports(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	now := start
+	a := newRTTTestSession(t, &now)
+	b := newRTTTestSession(t, &now)
+
+	// b needs RTP from a to report on it
+	for seq := uint16(1); seq <= 3; seq++ {
+		p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, seq, uint32(seq)*160, 44 /*ssrc*/)
+		data, err := a.Encode(p)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		_, err = b.Decode(data)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+
+	sr, err := a.NewRtcpSR(44)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	now = start.Add(30 * time.Millisecond)
+	sendRTCP(t, a, b, sr, 44, 1)
+
+	now = start.Add(130 * time.Millisecond)
+	rr, err := b.NewRtcpRR(55)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(rr.Reports), 1)
+	assertEqual(t, rr.Reports[0].LSR, uint32(sr.NTPTime>>16))
+	assertEqual(t, rr.Reports[0].DLSR, toShortNTP(100*time.Millisecond))
+
+	_, ok := a.GetRTT(55)
+	assertEqual(t, ok, false)
+
+	now = start.Add(160 * time.Millisecond)
+	sendRTCP(t, b, a, rr, 55, 1)
+
+	st, ok := a.GetRTT(55)
+	assertEqual(t, ok, true)
+	if st.Latest < 60*time.Millisecond || st.Latest > 60*time.Millisecond+100*time.Microsecond {
+		t.Errorf("rtt %v not close to 60ms", st.Latest)
+	}
+	assertEqual(t, st.Smoothed, st.Latest)
+	assertEqual(t, st.LastUpdate, now)
+
+	// a report with an unknown LSR is ignored
+	rr.Reports[0].LSR++
+	now = start.Add(time.Second)
+	sendRTCP(t, b, a, rr, 55, 2)
+	st2, _ := a.GetRTT(55)
+	assertEqual(t, st2, st)
+}
+
+func TestRTTFromDLRR(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	now := start
+	a := newRTTTestSession(t, &now)
+
+	xr, err := a.NewRtcpRRTR(44)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	rrtr := xr.Blocks[0].(*XRReceiverRefTimeBlock)
+	assertEqual(t, rrtr.NTPTime, toNTP(start))
+
+	reply := &ExtendedReport{SSRC: 66, Blocks: []XRBlock{
+		&XRDLRRBlock{Reports: []DLRRReport{{
+			SSRC: 44,
+			LRR:  uint32(rrtr.NTPTime >> 16),
+			DLRR: toShortNTP(50 * time.Millisecond),
+		}}},
+	}}
+
+	now = start.Add(250 * time.Millisecond)
+	a.processRTCP([]RTCPMessage{reply}, now)
+	st, ok := a.GetRTT(66)
+	assertEqual(t, ok, true)
+	if st.Latest < 200*time.Millisecond || st.Latest > 200*time.Millisecond+100*time.Microsecond {
+		t.Errorf("rtt %v not close to 200ms", st.Latest)
+	}
+
+	// the smoothed value moves an eighth of the way to a new sample
+	xr, _ = a.NewRtcpRRTR(44)
+	rrtr = xr.Blocks[0].(*XRReceiverRefTimeBlock)
+	reply.Blocks[0].(*XRDLRRBlock).Reports[0].LRR = uint32(rrtr.NTPTime >> 16)
+	reply.Blocks[0].(*XRDLRRBlock).Reports[0].DLRR = 0
+
+	now = now.Add(600 * time.Millisecond)
+	a.processRTCP([]RTCPMessage{reply}, now)
+	st2, _ := a.GetRTT(66)
+	assertEqual(t, st2.Latest, 600*time.Millisecond)
+	assertEqual(t, st2.Smoothed, st.Latest+(600*time.Millisecond-st.Latest)/8)
+}
@@ -0,0 +1,260 @@
+package rtp
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Constants from https://tools.ietf.org/html/rfc3550#appendix-A.7
+const (
+	rtcpMinTime           = 5 * time.Second
+	rtcpBandwidthFraction = 0.05
+	rtcpSenderBwFraction  = 0.25
+	rtcpRcvrBwFraction    = 1 - rtcpSenderBwFraction
+	rtcpCompensation      = math.E - 1.5
+)
+
+// Timer is a pending call scheduled with Clock.AfterFunc
+type Timer interface {
+	Stop() bool
+}
+
+// Clock provides the time and timers to a RTCPScheduler so tests can
+// replace it with one that does not sleep
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// RTCPScheduler decides when to send compound RTCP reports following the
+// timer rules with reconsideration from
+// https://tools.ietf.org/html/rfc3550#section-6.3
+type RTCPScheduler struct {
+	mu sync.Mutex
+
+	clock Clock
+	rand  func() float64
+	send  func() int
+
+	rtcpBw      float64 // octets per second
+	members     int
+	pmembers    int
+	senders     int
+	weSent      bool
+	avgRTCPSize float64 // octets
+	initial     bool
+
+	tp    time.Time
+	tn    time.Time
+	timer Timer
+	gen   uint64 // counts schedule calls so stale timer callbacks are ignored
+}
+
+// NewRTCPScheduler creates a scheduler for a session of bandwidth bits per
+// second. When a report is due send is called and must return the size in
+// octets of the compound packet it sent, including the UDP and IP headers.
+func NewRTCPScheduler(bandwidth float64, send func() int) *RTCPScheduler {
+	r := new(RTCPScheduler)
+	r.clock = systemClock{}
+	r.rand = rand.Float64
+	r.send = send
+
+	r.rtcpBw = bandwidth * rtcpBandwidthFraction / 8
+	r.members = 1
+	r.pmembers = 1
+	r.initial = true
+	// a guess at the size of the first RR with a SDES CNAME
+	r.avgRTCPSize = 100
+
+	return r
+}
+
+// SetClock replaces the wall clock and timers, it must be called before Start
+func (r *RTCPScheduler) SetClock(clock Clock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clock = clock
+}
+
+// SetRand replaces the source of random numbers in [0,1) used to
+// randomize the interval
+func (r *RTCPScheduler) SetRand(rand func() float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rand = rand
+}
+
+// Start schedules the first report
+func (r *RTCPScheduler) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tp = r.clock.Now()
+	r.tn = r.tp.Add(r.interval())
+	r.schedule()
+}
+
+// Stop cancels the pending report
+func (r *RTCPScheduler) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+}
+
+// NextReport returns the time the next report is scheduled for
+func (r *RTCPScheduler) NextReport() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.tn
+}
+
+// SetMembers sets the number of members in the session including us. When
+// members leave, the next report is brought forward by reverse
+// reconsideration as in https://tools.ietf.org/html/rfc3550#section-6.3.4
+func (r *RTCPScheduler) SetMembers(members int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if members < 1 {
+		members = 1
+	}
+	r.members = members
+
+	if r.members < r.pmembers && r.timer != nil {
+		tc := r.clock.Now()
+		ratio := float64(r.members) / float64(r.pmembers)
+		r.tn = tc.Add(time.Duration(ratio * float64(r.tn.Sub(tc))))
+		r.tp = tc.Add(-time.Duration(ratio * float64(tc.Sub(r.tp))))
+		r.pmembers = r.members
+
+		r.timer.Stop()
+		r.schedule()
+	}
+}
+
+// SetSenders sets the number of members that have sent RTP recently
+// including us, and whether we are one of them
+func (r *RTCPScheduler) SetSenders(senders int, weSent bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.senders = senders
+	r.weSent = weSent
+}
+
+// OnReceive updates the average RTCP packet size with a received compound
+// packet of size octets, including the UDP and IP headers
+func (r *RTCPScheduler) OnReceive(size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.updateAvgSize(size)
+}
+
+func (r *RTCPScheduler) updateAvgSize(size int) {
+	r.avgRTCPSize = float64(size)/16 + r.avgRTCPSize*15/16
+}
+
+// interval computes the randomized RTCP interval from
+// https://tools.ietf.org/html/rfc3550#section-6.3.1
+func (r *RTCPScheduler) interval() time.Duration {
+	minTime := rtcpMinTime
+	if r.initial {
+		minTime /= 2
+	}
+
+	// share the RTCP bandwidth between senders and receivers when
+	// senders are less than a quarter of the members
+	n := r.members
+	rtcpBw := r.rtcpBw
+	if float64(r.senders) <= float64(r.members)*rtcpSenderBwFraction {
+		if r.weSent {
+			rtcpBw *= rtcpSenderBwFraction
+			n = r.senders
+		} else {
+			rtcpBw *= rtcpRcvrBwFraction
+			n -= r.senders
+		}
+	}
+
+	t := time.Duration(math.MaxInt64)
+	if rtcpBw > 0 {
+		secs := r.avgRTCPSize * float64(n) / rtcpBw
+		if secs < float64(math.MaxInt64/time.Second) {
+			t = time.Duration(secs * float64(time.Second))
+		}
+	}
+	if t < minTime {
+		t = minTime
+	}
+
+	// randomize to avoid synchronization with other members and compensate
+	// for the bias of reconsideration towards shorter intervals
+	return time.Duration(float64(t) * (r.rand() + 0.5) / rtcpCompensation)
+}
+
+func (r *RTCPScheduler) schedule() {
+	r.gen++
+	gen := r.gen
+	d := r.tn.Sub(r.clock.Now())
+	r.timer = r.clock.AfterFunc(d, func() { r.onExpire(gen) })
+}
+
+// onExpire applies timer reconsideration, see
+// https://tools.ietf.org/html/rfc3550#section-6.3.6. A timer that was
+// replaced after it fired, by Stop and Start or SetMembers, has an old gen
+// and does nothing.
+func (r *RTCPScheduler) onExpire(gen uint64) {
+	r.mu.Lock()
+	if r.timer == nil || gen != r.gen {
+		// stopped or rescheduled
+		r.mu.Unlock()
+		return
+	}
+
+	tc := r.clock.Now()
+	tn := r.tp.Add(r.interval())
+	if tn.After(tc) {
+		r.tn = tn
+		r.pmembers = r.members
+		r.schedule()
+		r.mu.Unlock()
+		return
+	}
+	r.mu.Unlock()
+
+	// send without the lock so the callback may update the scheduler
+	size := r.send()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.updateAvgSize(size)
+	r.tp = tc
+	r.initial = false
+	r.pmembers = r.members
+	if r.timer == nil || gen != r.gen {
+		return
+	}
+	r.tn = tc.Add(r.interval())
+	r.schedule()
+}
@@ -0,0 +1,184 @@
+package rtp
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeTimer struct {
+	at      time.Time
+	f       func()
+	stopped bool
+}
+
+func (t *fakeTimer) Stop() bool {
+	wasActive := !t.stopped
+	t.stopped = true
+	return wasActive
+}
+
+type fakeClock struct {
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	t := &fakeTimer{at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d firing the timers that are due in order
+func (c *fakeClock) Advance(d time.Duration) {
+	end := c.now.Add(d)
+	for {
+		var next *fakeTimer
+		for _, t := range c.timers {
+			if !t.stopped && !t.at.After(end) && (next == nil || t.at.Before(next.at)) {
+				next = t
+			}
+		}
+		if next == nil {
+			break
+		}
+		next.stopped = true
+		c.now = next.at
+		next.f()
+	}
+	c.now = end
+}
+
+// compensated is the interval the scheduler picks for the deterministic
+// interval d when the random source returns rnd
+func compensated(d time.Duration, rnd float64) time.Duration {
+	return time.Duration(float64(d) * (rnd + 0.5) / rtcpCompensation)
+}
+
+func newTestScheduler(sent *int) (*RTCPScheduler, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1600000000, 0)}
+	r := NewRTCPScheduler(64000, func() int {
+		*sent++
+		return 100
+	})
+	r.SetClock(clock)
+	r.SetRand(func() float64 { return 0.5 })
+	return r, clock
+}
+
+func TestRTCPSchedulerInterval(t *testing.T) {
+	sent := 0
+	r, _ := newTestScheduler(&sent)
+
+	// a lone receiver uses the halved minimum interval at first
+	assertEqual(t, r.interval(), compensated(2500*time.Millisecond, 0.5))
+
+	r.initial = false
+	assertEqual(t, r.interval(), compensated(5*time.Second, 0.5))
+
+	// 100 receivers share 75% of the 400 octets/s RTCP bandwidth
+	r.SetMembers(100)
+	assertEqual(t, r.interval(), compensated(100*100*time.Second/300, 0.5))
+
+	// a single sender gets 25% of the RTCP bandwidth to itself
+	r.avgRTCPSize = 1000
+	r.SetSenders(1, true)
+	assertEqual(t, r.interval(), compensated(10*time.Second, 0.5))
+
+	// the randomization spans half to one and a half times the interval
+	r.SetRand(func() float64 { return 0 })
+	assertEqual(t, r.interval(), compensated(10*time.Second, 0))
+
+	// with many senders everyone shares the full bandwidth
+	r.SetRand(func() float64 { return 0.5 })
+	r.SetSenders(50, true)
+	assertEqual(t, r.interval(), compensated(100*1000*time.Second/400, 0.5))
+}
+
+func TestRTCPSchedulerSend(t *testing.T) {
+	sent := 0
+	r, clock := newTestScheduler(&sent)
+	start := clock.Now()
+
+	r.Start()
+	first := compensated(2500*time.Millisecond, 0.5)
+	assertEqual(t, r.NextReport(), start.Add(first))
+
+	clock.Advance(first - time.Millisecond)
+	assertEqual(t, sent, 0)
+	clock.Advance(time.Millisecond)
+	assertEqual(t, sent, 1)
+
+	regular := compensated(5*time.Second, 0.5)
+	assertEqual(t, r.NextReport(), start.Add(first+regular))
+
+	clock.Advance(3 * regular)
+	assertEqual(t, sent, 4)
+
+	r.Stop()
+	clock.Advance(3 * regular)
+	assertEqual(t, sent, 4)
+}
+
+func TestRTCPSchedulerStaleTimer(t *testing.T) {
+	sent := 0
+	r, clock := newTestScheduler(&sent)
+
+	// the first timer fires while Stop and Start replace it
+	r.Start()
+	stale := clock.timers[0]
+	r.Stop()
+	r.Start()
+	stale.f()
+	assertEqual(t, sent, 0)
+
+	active := 0
+	for _, timer := range clock.timers {
+		if !timer.stopped {
+			active++
+		}
+	}
+	assertEqual(t, active, 1)
+
+	// only the timer from the second Start sends
+	clock.Advance(compensated(2500*time.Millisecond, 0.5))
+	assertEqual(t, sent, 1)
+}
+
+func TestRTCPSchedulerReconsideration(t *testing.T) {
+	sent := 0
+	r, clock := newTestScheduler(&sent)
+	start := clock.Now()
+
+	r.Start()
+	first := compensated(2500*time.Millisecond, 0.5)
+
+	// members join before the timer fires so the report is postponed
+	r.SetMembers(100)
+	clock.Advance(first)
+	assertEqual(t, sent, 0)
+
+	large := compensated(100*100*time.Second/300, 0.5)
+	assertEqual(t, r.NextReport(), start.Add(large))
+
+	// half the members leave so the report is brought forward
+	clock.Advance(10 * time.Second)
+	tc := clock.Now()
+	r.SetMembers(50)
+	assertEqual(t, r.NextReport(), tc.Add(start.Add(large).Sub(tc)/2))
+
+	// the previous report time moved back so the shorter interval is due
+	clock.Advance(r.NextReport().Sub(tc))
+	assertEqual(t, sent, 1)
+}
+
+func TestRTCPSchedulerAvgSize(t *testing.T) {
+	sent := 0
+	r, _ := newTestScheduler(&sent)
+
+	r.OnReceive(260)
+	assertEqual(t, r.avgRTCPSize, float64(110))
+}
@@ -0,0 +1,209 @@
+package rtp
+
+/*
+Source Description is defined in
+https://tools.ietf.org/html/rfc3550#section-6.5
+
+        0                   1                   2                   3
+        0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+       +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+header |V=2|P|    SC   |  PT=SDES=202  |             length            |
+       +=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+
+chunk  |                          SSRC/CSRC_1                          |
+  1    +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+       |                           SDES items                          |
+       |                              ...                              |
+       +=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+
+
+Each item is a type, a length, and text. The item list of each chunk ends
+with a zero type and is padded to 32 bits.
+*/
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+type SDESItemType uint8
+
+const (
+	SDESEnd      SDESItemType = 0
+	SDESCNAME    SDESItemType = 1
+	SDESName     SDESItemType = 2
+	SDESEmail    SDESItemType = 3
+	SDESPhone    SDESItemType = 4
+	SDESLocation SDESItemType = 5
+	SDESTool     SDESItemType = 6
+	SDESNote     SDESItemType = 7
+	SDESPriv     SDESItemType = 8
+)
+
+// SDESItem is a single SDES item. Prefix is only used by PRIV items.
+type SDESItem struct {
+	Type   SDESItemType
+	Prefix string
+	Text   string
+}
+
+func (i *SDESItem) textLen() int {
+	if i.Type == SDESPriv {
+		return 1 + len(i.Prefix) + len(i.Text)
+	}
+	return len(i.Text)
+}
+
+// SDESChunk is the items describing one source
+type SDESChunk struct {
+	SSRC  uint32
+	Items []SDESItem
+}
+
+func (c *SDESChunk) size() int {
+	size := 4 + 1 // SSRC and end item
+	for i := range c.Items {
+		size += 2 + c.Items[i].textLen()
+	}
+	if size%4 != 0 {
+		size += 4 - size%4
+	}
+	return size
+}
+
+// SourceDescription is a RTCP SDES packet
+type SourceDescription struct {
+	Chunks []SDESChunk
+}
+
+// GetCNAME returns the CNAME of ssrc if the packet has one
+func (d *SourceDescription) GetCNAME(ssrc uint32) (string, bool) {
+	for _, c := range d.Chunks {
+		if c.SSRC != ssrc {
+			continue
+		}
+		for _, item := range c.Items {
+			if item.Type == SDESCNAME {
+				return item.Text, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (d *SourceDescription) hasCNAME() bool {
+	for _, c := range d.Chunks {
+		for _, item := range c.Items {
+			if item.Type == SDESCNAME {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (d *SourceDescription) GetPT() RTCPTypeClass {
+	return RTCPTypeSDES
+}
+
+func (d *SourceDescription) Marshal() ([]byte, error) {
+	size := 4
+	for i := range d.Chunks {
+		size += d.Chunks[i].size()
+	}
+	buffer := make([]byte, size)
+
+	_, err := newRTCPHeader(buffer, RTCPTypeSDES, len(d.Chunks))
+	if err != nil {
+		return nil, err
+	}
+
+	offset := 4
+	for _, c := range d.Chunks {
+		binary.BigEndian.PutUint32(buffer[offset:], c.SSRC)
+		pos := offset + 4
+
+		for _, item := range c.Items {
+			if item.Type == SDESEnd {
+				return nil, errors.New("rtcp: SDES item can not use the end type")
+			}
+			if item.textLen() > 255 {
+				return nil, errors.New("rtcp: SDES item longer than 255 bytes")
+			}
+
+			buffer[pos] = byte(item.Type)
+			buffer[pos+1] = byte(item.textLen())
+			pos += 2
+			if item.Type == SDESPriv {
+				buffer[pos] = byte(len(item.Prefix))
+				pos += 1 + copy(buffer[pos+1:], item.Prefix)
+			}
+			pos += copy(buffer[pos:], item.Text)
+		}
+
+		// the rest of the chunk is already zero which ends the item list
+		offset += c.size()
+	}
+
+	return buffer, nil
+}
+
+func (d *SourceDescription) Unmarshal(buffer []byte) error {
+	return unmarshalRTCP(buffer, d)
+}
+
+func (d *SourceDescription) unmarshalPacket(p *RTCPPacket) error {
+	if p.GetPT() != RTCPTypeSDES {
+		return errors.New("rtcp: packet is not a SDES")
+	}
+
+	// the first chunk starts in the sender SSRC position of the header
+	body := append(append([]byte{}, p.header.buffer[4:]...), p.GetPayload()...)
+
+	chunks := make([]SDESChunk, p.header.GetRC())
+	offset := 0
+	for i := range chunks {
+		if offset+4 > len(body) {
+			return errors.New("rtcp: SDES chunk larger than packet")
+		}
+		chunks[i].SSRC = binary.BigEndian.Uint32(body[offset:])
+		pos := offset + 4
+
+		for {
+			if pos >= len(body) {
+				return errors.New("rtcp: SDES chunk missing end item")
+			}
+			itemType := SDESItemType(body[pos])
+			if itemType == SDESEnd {
+				pos++
+				break
+			}
+
+			if pos+2 > len(body) || pos+2+int(body[pos+1]) > len(body) {
+				return errors.New("rtcp: SDES item larger than packet")
+			}
+			text := body[pos+2 : pos+2+int(body[pos+1])]
+			pos += 2 + len(text)
+
+			item := SDESItem{Type: itemType}
+			if itemType == SDESPriv {
+				if len(text) < 1 || 1+int(text[0]) > len(text) {
+					return errors.New("rtcp: SDES PRIV prefix larger than item")
+				}
+				item.Prefix = string(text[1 : 1+int(text[0])])
+				text = text[1+int(text[0]):]
+			}
+			item.Text = string(text)
+
+			chunks[i].Items = append(chunks[i].Items, item)
+		}
+
+		// next chunk starts on a 32 bit boundary
+		if pos%4 != 0 {
+			pos += 4 - pos%4
+		}
+		offset = pos
+	}
+
+	d.Chunks = chunks
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package rtp
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestSourceDescription(t *testing.T) {
+	sdes := &SourceDescription{
+		Chunks: []SDESChunk{
+			{
+				SSRC: 0xbcdc0094,
+				Items: []SDESItem{
+					{Type: SDESCNAME, Text: "abc"},
+				},
+			},
+			{
+				SSRC: 0x11223344,
+				Items: []SDESItem{
+					{Type: SDESCNAME, Text: "user@host"},
+					{Type: SDESTool, Text: "goRtp"},
+					{Type: SDESPriv, Prefix: "x", Text: "yz"},
+				},
+			},
+		},
+	}
+
+	data, err := sdes.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	golden, _ := hex.DecodeString("82ca000b" +
+		"bcdc0094" + "0103616263000000" +
+		"11223344" + "0109757365724068" + "6f73740605676f52" + "7470080401787" + "97a00000000")
+	compareByteArrays(t, data, golden)
+
+	var sdes2 SourceDescription
+	err = sdes2.Unmarshal(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(sdes2.Chunks), 2)
+	assertEqual(t, sdes2.Chunks[0].SSRC, uint32(0xbcdc0094))
+	assertEqual(t, len(sdes2.Chunks[1].Items), 3)
+	assertEqual(t, sdes2.Chunks[1].Items[1], SDESItem{Type: SDESTool, Text: "goRtp"})
+	assertEqual(t, sdes2.Chunks[1].Items[2], SDESItem{Type: SDESPriv, Prefix: "x", Text: "yz"})
+
+	cname, ok := sdes2.GetCNAME(0x11223344)
+	assertEqual(t, ok, true)
+	assertEqual(t, cname, "user@host")
+
+	_, ok = sdes2.GetCNAME(0x55)
+	assertEqual(t, ok, false)
+
+	// chunk with no end item
+	data[len(data)-4] = 1
+	data[len(data)-3] = 2
+	err = sdes2.Unmarshal(data)
+	if err == nil {
+		t.Errorf("SDES with missing end item was accepted")
+	}
+}
+
+func TestSourceDescriptionEmpty(t *testing.T) {
+	sdes := &SourceDescription{}
+
+	data, err := sdes.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	compareByteArrays(t, data, []byte{0x80, 0xca, 0, 0})
+
+	err = sdes.Unmarshal(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(sdes.Chunks), 0)
+
+	// a chunk with no items is a SSRC and a 4 byte end
+	sdes.Chunks = []SDESChunk{{SSRC: 7}}
+	data, err = sdes.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(data), 12)
+}
@@ -0,0 +1,375 @@
+package rtp
+
+/*
+Transport wide congestion control feedback is defined in
+https://tools.ietf.org/html/draft-holmer-rmcat-transport-wide-cc-extensions-01#section-3.1
+
+    0                   1                   2                   3
+    0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |V=2|P|  FMT=15 |    PT=205     |           length              |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |                     SSRC of packet sender                     |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |                      SSRC of media source                     |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |      base sequence number     |      packet status count      |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |                 reference time                | fb pkt. count |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |          packet chunk         |         packet chunk          |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   .                                                               .
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |         packet chunk          |  recv delta   |  recv delta   |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   .                                                               .
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+Packet chunks are either a run length of one status or a vector of 14 one
+bit or 7 two bit statuses. Receive deltas are in 250us units, one byte for
+small deltas and two signed bytes for large deltas.
+*/
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+const (
+	FMTTransportCC = 15
+)
+
+const (
+	twccRefTimeUnit = 64 * time.Millisecond
+	twccDeltaUnit   = 250 * time.Microsecond
+
+	twccNotReceived = 0
+	twccSmallDelta  = 1
+	twccLargeDelta  = 2
+
+	twccMaxRunLength = 0x1FFF
+)
+
+// TWCCRecord is the arrival of the packet with transport wide sequence
+// number Seq. Arrival is on the receiver clock and is only set when the
+// packet was received.
+type TWCCRecord struct {
+	Seq      uint16
+	Received bool
+	Arrival  time.Duration
+}
+
+// TransportCCFeedback is a RTPFB transport wide congestion control feedback
+// message. Packets has one record for every sequence number from BaseSeq.
+type TransportCCFeedback struct {
+	SenderSSRC    uint32
+	MediaSSRC     uint32
+	BaseSeq       uint16
+	ReferenceTime int32 // 24 bit signed in multiples of 64ms
+	FbPktCount    uint8
+	Packets       []TWCCRecord
+}
+
+// floorDiv divides rounding towards negative infinity
+func floorDiv(a, b time.Duration) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return int64(q)
+}
+
+// NewTransportCCFeedback forms the feedback for the received packets in
+// records. Sequence numbers that are between the lowest and highest
+// received are reported as not received.
+func NewTransportCCFeedback(sender, media uint32, fbPktCount uint8, records []TWCCRecord) (*TransportCCFeedback, error) {
+	var received []TWCCRecord
+	for _, r := range records {
+		if r.Received {
+			received = append(received, r)
+		}
+	}
+	if len(received) == 0 {
+		return nil, errors.New("rtcp: transport cc feedback needs a received packet")
+	}
+
+	// unwrap the sequence numbers relative to the first record
+	first := received[0].Seq
+	minOffset, maxOffset := 0, 0
+	for _, r := range received {
+		offset := int(int16(r.Seq - first))
+		if offset < minOffset {
+			minOffset = offset
+		}
+		if offset > maxOffset {
+			maxOffset = offset
+		}
+	}
+	count := maxOffset - minOffset + 1
+	if count > 0xFFFF {
+		return nil, errors.New("rtcp: transport cc feedback covers too many packets")
+	}
+
+	f := &TransportCCFeedback{
+		SenderSSRC: sender,
+		MediaSSRC:  media,
+		BaseSeq:    first + uint16(minOffset),
+		FbPktCount: fbPktCount,
+		Packets:    make([]TWCCRecord, count),
+	}
+	for i := range f.Packets {
+		f.Packets[i].Seq = f.BaseSeq + uint16(i)
+	}
+	for _, r := range received {
+		i := int(r.Seq - f.BaseSeq)
+		if !f.Packets[i].Received {
+			f.Packets[i] = r
+		}
+	}
+
+	for _, r := range f.Packets {
+		if r.Received {
+			f.ReferenceTime = int32(floorDiv(r.Arrival, twccRefTimeUnit))
+			break
+		}
+	}
+
+	return f, nil
+}
+
+func (f *TransportCCFeedback) GetPT() RTCPTypeClass {
+	return RTCPTypeRTPFB
+}
+
+// symbols works out the status of each packet and its receive delta in
+// 250us units
+func (f *TransportCCFeedback) symbols() ([]int, []int64, error) {
+	symbols := make([]int, len(f.Packets))
+	deltas := make([]int64, 0, len(f.Packets))
+
+	last := int64(f.ReferenceTime) * int64(twccRefTimeUnit/twccDeltaUnit)
+	for i, r := range f.Packets {
+		if !r.Received {
+			symbols[i] = twccNotReceived
+			continue
+		}
+
+		ticks := floorDiv(r.Arrival+twccDeltaUnit/2, twccDeltaUnit)
+		delta := ticks - last
+		last = ticks
+
+		switch {
+		case delta >= 0 && delta <= 0xFF:
+			symbols[i] = twccSmallDelta
+		case delta >= -0x8000 && delta <= 0x7FFF:
+			symbols[i] = twccLargeDelta
+		default:
+			return nil, nil, errors.New("rtcp: transport cc receive delta too large")
+		}
+		deltas = append(deltas, delta)
+	}
+
+	return symbols, deltas, nil
+}
+
+// packChunks encodes symbols as run length and status vector chunks
+func packChunks(symbols []int) []uint16 {
+	var chunks []uint16
+
+	for i := 0; i < len(symbols); {
+		remaining := len(symbols) - i
+
+		run := 1
+		for run < remaining && run < twccMaxRunLength && symbols[i+run] == symbols[i] {
+			run++
+		}
+		if run >= 14 || run == remaining {
+			chunks = append(chunks, uint16(symbols[i])<<13|uint16(run))
+			i += run
+			continue
+		}
+
+		oneBit := true
+		for j := i; j < i+14 && j < len(symbols); j++ {
+			if symbols[j] == twccLargeDelta {
+				oneBit = false
+			}
+		}
+
+		if oneBit {
+			chunk := uint16(0x8000)
+			for j := 0; j < 14 && i+j < len(symbols); j++ {
+				chunk |= uint16(symbols[i+j]) << (13 - j)
+			}
+			chunks = append(chunks, chunk)
+			i += 14
+		} else {
+			chunk := uint16(0xC000)
+			for j := 0; j < 7 && i+j < len(symbols); j++ {
+				chunk |= uint16(symbols[i+j]) << (12 - 2*j)
+			}
+			chunks = append(chunks, chunk)
+			i += 7
+		}
+	}
+
+	return chunks
+}
+
+// unpackChunks decodes count symbols from the chunks at the start of fci and
+// returns them with the number of bytes used
+func unpackChunks(fci []byte, count int) ([]int, int, error) {
+	symbols := make([]int, 0, count)
+	offset := 0
+
+	for len(symbols) < count {
+		if offset+2 > len(fci) {
+			return nil, 0, errors.New("rtcp: transport cc chunks larger than packet")
+		}
+		chunk := binary.BigEndian.Uint16(fci[offset:])
+		offset += 2
+
+		switch {
+		case chunk&0x8000 == 0:
+			symbol := int(chunk>>13) & 0x03
+			run := int(chunk & twccMaxRunLength)
+			for j := 0; j < run && len(symbols) < count; j++ {
+				symbols = append(symbols, symbol)
+			}
+		case chunk&0x4000 == 0:
+			for j := 0; j < 14 && len(symbols) < count; j++ {
+				symbols = append(symbols, int(chunk>>(13-j))&0x01)
+			}
+		default:
+			for j := 0; j < 7 && len(symbols) < count; j++ {
+				symbols = append(symbols, int(chunk>>(12-2*j))&0x03)
+			}
+		}
+	}
+
+	return symbols, offset, nil
+}
+
+func (f *TransportCCFeedback) Marshal() ([]byte, error) {
+	if len(f.Packets) == 0 || len(f.Packets) > 0xFFFF {
+		return nil, errors.New("rtcp: transport cc feedback packet count out of range")
+	}
+
+	symbols, deltas, err := f.symbols()
+	if err != nil {
+		return nil, err
+	}
+	chunks := packChunks(symbols)
+
+	fciLen := 8 + 2*len(chunks)
+	for _, s := range symbols {
+		fciLen += s // small deltas are one byte and large are two
+	}
+
+	// pad to 32 bits with the RTCP padding, the last byte being its length
+	pad := 0
+	if fciLen%4 != 0 {
+		pad = 4 - fciLen%4
+	}
+
+	buffer, err := marshalFeedback(RTCPTypeRTPFB, FMTTransportCC, f.SenderSSRC, f.MediaSSRC, fciLen+pad)
+	if err != nil {
+		return nil, err
+	}
+	if pad > 0 {
+		h := RTCPHeader{buffer: buffer}
+		h.SetPadding(true)
+		buffer[len(buffer)-1] = byte(pad)
+	}
+
+	fci := buffer[feedbackHeaderSize:]
+	binary.BigEndian.PutUint16(fci[0:], f.BaseSeq)
+	binary.BigEndian.PutUint16(fci[2:], uint16(len(f.Packets)))
+	binary.BigEndian.PutUint32(fci[4:], uint32(f.ReferenceTime)<<8|uint32(f.FbPktCount))
+
+	offset := 8
+	for _, chunk := range chunks {
+		binary.BigEndian.PutUint16(fci[offset:], chunk)
+		offset += 2
+	}
+
+	d := 0
+	for _, s := range symbols {
+		switch s {
+		case twccSmallDelta:
+			fci[offset] = byte(deltas[d])
+			offset++
+			d++
+		case twccLargeDelta:
+			binary.BigEndian.PutUint16(fci[offset:], uint16(int16(deltas[d])))
+			offset += 2
+			d++
+		}
+	}
+
+	return buffer, nil
+}
+
+func (f *TransportCCFeedback) Unmarshal(buffer []byte) error {
+	return unmarshalRTCP(buffer, f)
+}
+
+func (f *TransportCCFeedback) unmarshalPacket(p *RTCPPacket) error {
+	sender, media, fci, err := unmarshalFeedback(p, RTCPTypeRTPFB, FMTTransportCC)
+	if err != nil {
+		return err
+	}
+	if len(fci) < 8 {
+		return errors.New("rtcp: transport cc feedback too short")
+	}
+
+	baseSeq := binary.BigEndian.Uint16(fci[0:])
+	count := int(binary.BigEndian.Uint16(fci[2:]))
+	refTime := int32(binary.BigEndian.Uint32(fci[4:])) >> 8 // sign extend 24 bits
+	fbPktCount := fci[7]
+
+	symbols, used, err := unpackChunks(fci[8:], count)
+	if err != nil {
+		return err
+	}
+
+	packets := make([]TWCCRecord, count)
+	offset := 8 + used
+	arrival := time.Duration(refTime) * twccRefTimeUnit
+	for i, s := range symbols {
+		packets[i].Seq = baseSeq + uint16(i)
+
+		switch s {
+		case twccSmallDelta:
+			if offset+1 > len(fci) {
+				return errors.New("rtcp: transport cc deltas larger than packet")
+			}
+			arrival += time.Duration(fci[offset]) * twccDeltaUnit
+			offset++
+		case twccLargeDelta:
+			if offset+2 > len(fci) {
+				return errors.New("rtcp: transport cc deltas larger than packet")
+			}
+			arrival += time.Duration(int16(binary.BigEndian.Uint16(fci[offset:]))) * twccDeltaUnit
+			offset += 2
+		case twccNotReceived:
+			continue
+		default:
+			return errors.New("rtcp: transport cc reserved packet status")
+		}
+
+		packets[i].Received = true
+		packets[i].Arrival = arrival
+	}
+
+	f.SenderSSRC = sender
+	f.MediaSSRC = media
+	f.BaseSeq = baseSeq
+	f.ReferenceTime = refTime
+	f.FbPktCount = fbPktCount
+	f.Packets = packets
+
+	return nil
+}
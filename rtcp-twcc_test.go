@@ -0,0 +1,165 @@
+package rtp
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestTransportCCFeedbackGolden(t *testing.T) {
+	records := []TWCCRecord{
+		{Seq: 7, Received: true, Arrival: 67 * time.Millisecond},
+		{Seq: 5, Received: true, Arrival: 65 * time.Millisecond},
+	}
+
+	f, err := NewTransportCCFeedback(1, 2, 9, records)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, f.BaseSeq, uint16(5))
+	assertEqual(t, len(f.Packets), 3)
+	assertEqual(t, f.Packets[1].Received, false)
+	assertEqual(t, f.ReferenceTime, int32(1))
+
+	data, err := f.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	golden, _ := hex.DecodeString("8fcd0005" + "00000001" + "00000002" +
+		"00050003" + "00000109" + "a8000408")
+	compareByteArrays(t, data, golden)
+
+	var f2 TransportCCFeedback
+	err = f2.Unmarshal(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, f2.FbPktCount, uint8(9))
+	assertEqual(t, len(f2.Packets), 3)
+	assertEqual(t, f2.Packets[0], TWCCRecord{Seq: 5, Received: true, Arrival: 65 * time.Millisecond})
+	assertEqual(t, f2.Packets[1], TWCCRecord{Seq: 6})
+	assertEqual(t, f2.Packets[2], TWCCRecord{Seq: 7, Received: true, Arrival: 67 * time.Millisecond})
+
+	// one small delta leaves a byte of RTCP padding
+	f, err = NewTransportCCFeedback(1, 2, 9, records[:1])
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	data, err = f.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	golden, _ = hex.DecodeString("afcd0005" + "00000001" + "00000002" +
+		"00070001" + "00000109" + "20010c01")
+	compareByteArrays(t, data, golden)
+
+	// the padded feedback can be followed by another packet
+	sdes, err := (&SourceDescription{Chunks: []SDESChunk{
+		{SSRC: 1, Items: []SDESItem{{Type: SDESCNAME, Text: "a"}}},
+	}}).Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	p, err := NewRTCPCompoundPacket(append(data, sdes...), 0)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	p.SetReducedSize(true)
+	msgs, err := p.GetMessages()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(msgs), 2)
+	f3, ok := msgs[0].(*TransportCCFeedback)
+	if !ok {
+		t.Fatalf("Transport cc feedback not parsed")
+	}
+	assertEqual(t, len(f3.Packets), 1)
+	assertEqual(t, f3.Packets[0], TWCCRecord{Seq: 7, Received: true, Arrival: 67 * time.Millisecond})
+}
+
+func TestTransportCCFeedbackRoundTrip(t *testing.T) {
+	base := 10 * time.Second
+	var records []TWCCRecord
+
+	// steady packets across a sequence number wrap
+	seq := uint16(65500)
+	arrival := base
+	for i := 0; i < 50; i++ {
+		records = append(records, TWCCRecord{Seq: seq, Received: true, Arrival: arrival})
+		seq++
+		arrival += 5 * time.Millisecond
+	}
+	// a burst of loss long enough for a run length chunk
+	seq += 40
+	// a late packet needs a large delta and one arriving out of order
+	// needs a negative delta
+	arrival += 200 * time.Millisecond
+	records = append(records, TWCCRecord{Seq: seq, Received: true, Arrival: arrival})
+	records = append(records, TWCCRecord{Seq: seq + 1, Received: true, Arrival: arrival - 2*time.Millisecond})
+	// packets arriving together
+	for i := uint16(2); i < 20; i++ {
+		records = append(records, TWCCRecord{Seq: seq + i, Received: true, Arrival: arrival})
+	}
+
+	f, err := NewTransportCCFeedback(1, 2, 0, records)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, f.BaseSeq, uint16(65500))
+	assertEqual(t, len(f.Packets), 50+40+20)
+
+	data, err := f.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	rr, err := (&ReceiverReport{SSRC: 1}).Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	p, err := NewRTCPCompoundPacket(append(rr, data...), 0)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	msgs, err := p.GetMessages()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	f2, ok := msgs[1].(*TransportCCFeedback)
+	if !ok {
+		t.Fatalf("Transport cc feedback not parsed")
+	}
+
+	assertEqual(t, len(f2.Packets), len(f.Packets))
+	for i := range f.Packets {
+		assertEqual(t, f2.Packets[i], f.Packets[i])
+	}
+}
+
+func TestTransportCCFeedbackErrors(t *testing.T) {
+	_, err := NewTransportCCFeedback(1, 2, 0, []TWCCRecord{{Seq: 1}})
+	if err == nil {
+		t.Errorf("Feedback with no received packets was accepted")
+	}
+
+	f, err := NewTransportCCFeedback(1, 2, 0, []TWCCRecord{
+		{Seq: 1, Received: true, Arrival: 0},
+		{Seq: 2, Received: true, Arrival: 10 * time.Second},
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	_, err = f.Marshal()
+	if err == nil {
+		t.Errorf("Delta larger than 16 bits was accepted")
+	}
+
+	data, _ := hex.DecodeString("8fcd0004" + "00000001" + "00000002" + "00050003" + "00000109")
+	var f2 TransportCCFeedback
+	err = f2.Unmarshal(data)
+	if err == nil {
+		t.Errorf("Feedback with missing chunks was accepted")
+	}
+}
@@ -0,0 +1,496 @@
+package rtp
+
+/*
+Extended Reports are defined in https://tools.ietf.org/html/rfc3611
+
+    0                   1                   2                   3
+    0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |V=2|P|reserved |   PT=XR=207   |             length            |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |                              SSRC                             |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   :                         report blocks                         :
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+Each report block starts with
+
+    0                   1                   2                   3
+    0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |      BT       | type-specific |         block length          |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   :             type-specific block contents                      :
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+where the block length is the number of 32 bit words after the first.
+*/
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// XR report block types
+const (
+	XRLossRLE           = 1
+	XRDuplicateRLE      = 2
+	XRReceiverRefTime   = 4
+	XRDLRR              = 5
+	XRStatisticsSummary = 6
+	XRVoIPMetrics       = 7
+)
+
+const (
+	xrBlockHeaderSize     = 4
+	xrStatisticsSize      = 36
+	xrVoIPMetricsSize     = 32
+	xrReceiverRefTimeSize = 8
+)
+
+// XRBlock is a report block in an Extended Report
+type XRBlock interface {
+	BlockType() uint8
+	// marshal returns the type specific byte and the block contents
+	marshal() (byte, []byte, error)
+	unmarshal(typeSpecific byte, contents []byte) error
+}
+
+// ExtendedReport is a RTCP XR packet
+type ExtendedReport struct {
+	SSRC   uint32
+	Blocks []XRBlock
+}
+
+func (x *ExtendedReport) GetPT() RTCPTypeClass {
+	return RTCPTypeXR
+}
+
+func (x *ExtendedReport) Marshal() ([]byte, error) {
+	buffer := make([]byte, rtcpHeaderSize)
+
+	for _, b := range x.Blocks {
+		typeSpecific, contents, err := b.marshal()
+		if err != nil {
+			return nil, err
+		}
+		if len(contents)%4 != 0 || len(contents)/4 > 0xFFFF {
+			return nil, errors.New("rtcp: XR block has bad length")
+		}
+
+		block := make([]byte, xrBlockHeaderSize, xrBlockHeaderSize+len(contents))
+		block[0] = b.BlockType()
+		block[1] = typeSpecific
+		binary.BigEndian.PutUint16(block[2:], uint16(len(contents)/4))
+		buffer = append(buffer, append(block, contents...)...)
+	}
+
+	h, err := newRTCPHeader(buffer, RTCPTypeXR, 0)
+	if err != nil {
+		return nil, err
+	}
+	h.SetSenderSSRC(x.SSRC)
+
+	return buffer, nil
+}
+
+func (x *ExtendedReport) Unmarshal(buffer []byte) error {
+	return unmarshalRTCP(buffer, x)
+}
+
+func (x *ExtendedReport) unmarshalPacket(p *RTCPPacket) error {
+	if p.GetPT() != RTCPTypeXR {
+		return errors.New("rtcp: packet is not a XR")
+	}
+	if len(p.header.buffer) < rtcpHeaderSize {
+		return errors.New("rtcp: XR too short for SSRC")
+	}
+
+	var blocks []XRBlock
+	payload := p.GetPayload()
+	for offset := 0; offset < len(payload); {
+		if offset+xrBlockHeaderSize > len(payload) {
+			return errors.New("rtcp: XR block header larger than packet")
+		}
+		end := offset + xrBlockHeaderSize + 4*int(binary.BigEndian.Uint16(payload[offset+2:]))
+		if end > len(payload) {
+			return errors.New("rtcp: XR block larger than packet")
+		}
+
+		var b XRBlock
+		switch payload[offset] {
+		case XRLossRLE:
+			b = &XRLossRLEBlock{}
+		case XRDuplicateRLE:
+			b = &XRDuplicateRLEBlock{}
+		case XRReceiverRefTime:
+			b = &XRReceiverRefTimeBlock{}
+		case XRDLRR:
+			b = &XRDLRRBlock{}
+		case XRStatisticsSummary:
+			b = &XRStatisticsSummaryBlock{}
+		case XRVoIPMetrics:
+			b = &XRVoIPMetricsBlock{}
+		default:
+			b = &XRUnknownBlock{Type: payload[offset]}
+		}
+
+		err := b.unmarshal(payload[offset+1], payload[offset+xrBlockHeaderSize:end])
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, b)
+
+		offset = end
+	}
+
+	x.SSRC = p.header.GetSenderSSRC()
+	x.Blocks = blocks
+
+	return nil
+}
+
+// XRUnknownBlock holds a report block of a type that is not decoded
+type XRUnknownBlock struct {
+	Type         uint8
+	TypeSpecific byte
+	Contents     []byte
+}
+
+func (b *XRUnknownBlock) BlockType() uint8 {
+	return b.Type
+}
+
+func (b *XRUnknownBlock) marshal() (byte, []byte, error) {
+	return b.TypeSpecific, b.Contents, nil
+}
+
+func (b *XRUnknownBlock) unmarshal(typeSpecific byte, contents []byte) error {
+	b.TypeSpecific = typeSpecific
+	b.Contents = append([]byte{}, contents...)
+	return nil
+}
+
+// xrRLE is the layout shared by the Loss RLE and Duplicate RLE blocks.
+// Chunks are the raw 16 bit run length and bit vector chunks of
+// https://tools.ietf.org/html/rfc3611#section-4.1.1 and a trailing null
+// chunk is added when needed to pad to 32 bits.
+type xrRLE struct {
+	Thinning uint8
+	SSRC     uint32
+	BeginSeq uint16
+	EndSeq   uint16
+	Chunks   []uint16
+}
+
+func (r *xrRLE) marshal() (byte, []byte, error) {
+	if r.Thinning > 15 {
+		return 0, nil, errors.New("rtcp: XR RLE thinning out of range")
+	}
+
+	n := len(r.Chunks)
+	if n%2 != 0 {
+		n++
+	}
+
+	contents := make([]byte, 8+2*n)
+	binary.BigEndian.PutUint32(contents[0:], r.SSRC)
+	binary.BigEndian.PutUint16(contents[4:], r.BeginSeq)
+	binary.BigEndian.PutUint16(contents[6:], r.EndSeq)
+	for i, chunk := range r.Chunks {
+		binary.BigEndian.PutUint16(contents[8+2*i:], chunk)
+	}
+
+	return r.Thinning & 0x0F, contents, nil
+}
+
+func (r *xrRLE) unmarshal(typeSpecific byte, contents []byte) error {
+	if len(contents) < 8 {
+		return errors.New("rtcp: XR RLE block too short")
+	}
+
+	r.Thinning = typeSpecific & 0x0F
+	r.SSRC = binary.BigEndian.Uint32(contents[0:])
+	r.BeginSeq = binary.BigEndian.Uint16(contents[4:])
+	r.EndSeq = binary.BigEndian.Uint16(contents[6:])
+	r.Chunks = nil
+	for i := 8; i+2 <= len(contents); i += 2 {
+		chunk := binary.BigEndian.Uint16(contents[i:])
+		if chunk == 0 { // null chunk pads the block
+			continue
+		}
+		r.Chunks = append(r.Chunks, chunk)
+	}
+
+	return nil
+}
+
+// XRLossRLEBlock reports which packets from BeginSeq up to but not
+// including EndSeq were received
+type XRLossRLEBlock struct {
+	xrRLE
+}
+
+func (b *XRLossRLEBlock) BlockType() uint8 {
+	return XRLossRLE
+}
+
+// XRDuplicateRLEBlock reports which packets from BeginSeq up to but not
+// including EndSeq were duplicated
+type XRDuplicateRLEBlock struct {
+	xrRLE
+}
+
+func (b *XRDuplicateRLEBlock) BlockType() uint8 {
+	return XRDuplicateRLE
+}
+
+// XRReceiverRefTimeBlock lets a receiver that does not send SRs have its
+// round trip time measured
+type XRReceiverRefTimeBlock struct {
+	NTPTime uint64
+}
+
+func (b *XRReceiverRefTimeBlock) BlockType() uint8 {
+	return XRReceiverRefTime
+}
+
+func (b *XRReceiverRefTimeBlock) marshal() (byte, []byte, error) {
+	contents := make([]byte, xrReceiverRefTimeSize)
+	binary.BigEndian.PutUint64(contents, b.NTPTime)
+	return 0, contents, nil
+}
+
+func (b *XRReceiverRefTimeBlock) unmarshal(typeSpecific byte, contents []byte) error {
+	if len(contents) != xrReceiverRefTimeSize {
+		return errors.New("rtcp: XR receiver reference time block wrong length")
+	}
+	b.NTPTime = binary.BigEndian.Uint64(contents)
+	return nil
+}
+
+// DLRRReport is the reply to the receiver reference time of SSRC
+type DLRRReport struct {
+	SSRC uint32
+	LRR  uint32 // middle 32 bits of the last receiver reference time
+	DLRR uint32 // delay since last receiver reference in 1/65536 seconds
+}
+
+// XRDLRRBlock carries the delay since the last receiver reference time
+// report from each receiver
+type XRDLRRBlock struct {
+	Reports []DLRRReport
+}
+
+func (b *XRDLRRBlock) BlockType() uint8 {
+	return XRDLRR
+}
+
+func (b *XRDLRRBlock) marshal() (byte, []byte, error) {
+	contents := make([]byte, 12*len(b.Reports))
+	for i, r := range b.Reports {
+		binary.BigEndian.PutUint32(contents[12*i:], r.SSRC)
+		binary.BigEndian.PutUint32(contents[12*i+4:], r.LRR)
+		binary.BigEndian.PutUint32(contents[12*i+8:], r.DLRR)
+	}
+	return 0, contents, nil
+}
+
+func (b *XRDLRRBlock) unmarshal(typeSpecific byte, contents []byte) error {
+	if len(contents)%12 != 0 {
+		return errors.New("rtcp: XR DLRR block wrong length")
+	}
+
+	b.Reports = make([]DLRRReport, len(contents)/12)
+	for i := range b.Reports {
+		b.Reports[i].SSRC = binary.BigEndian.Uint32(contents[12*i:])
+		b.Reports[i].LRR = binary.BigEndian.Uint32(contents[12*i+4:])
+		b.Reports[i].DLRR = binary.BigEndian.Uint32(contents[12*i+8:])
+	}
+	return nil
+}
+
+// Type of TTL or hop limit in a statistics summary block
+const (
+	XRToHNone = 0
+	XRToHIPv4 = 1
+	XRToHIPv6 = 2
+)
+
+// XRStatisticsSummaryBlock summarises the packets from BeginSeq up to but
+// not including EndSeq. The Loss, Dup, Jitter and ToH flags say which
+// fields are valid.
+type XRStatisticsSummaryBlock struct {
+	LossReports   bool
+	DupReports    bool
+	JitterReports bool
+	ToH           uint8
+
+	SSRC        uint32
+	BeginSeq    uint16
+	EndSeq      uint16
+	LostPackets uint32
+	DupPackets  uint32
+	MinJitter   uint32
+	MaxJitter   uint32
+	MeanJitter  uint32
+	DevJitter   uint32
+	MinTTLOrHL  uint8
+	MaxTTLOrHL  uint8
+	MeanTTLOrHL uint8
+	DevTTLOrHL  uint8
+}
+
+func (b *XRStatisticsSummaryBlock) BlockType() uint8 {
+	return XRStatisticsSummary
+}
+
+func (b *XRStatisticsSummaryBlock) marshal() (byte, []byte, error) {
+	// ToH 3 is reserved
+	if b.ToH > XRToHIPv6 {
+		return 0, nil, errors.New("rtcp: XR statistics summary ToH out of range")
+	}
+
+	var typeSpecific byte = b.ToH << 3
+	if b.LossReports {
+		typeSpecific |= 0x80
+	}
+	if b.DupReports {
+		typeSpecific |= 0x40
+	}
+	if b.JitterReports {
+		typeSpecific |= 0x20
+	}
+
+	contents := make([]byte, xrStatisticsSize)
+	binary.BigEndian.PutUint32(contents[0:], b.SSRC)
+	binary.BigEndian.PutUint16(contents[4:], b.BeginSeq)
+	binary.BigEndian.PutUint16(contents[6:], b.EndSeq)
+	binary.BigEndian.PutUint32(contents[8:], b.LostPackets)
+	binary.BigEndian.PutUint32(contents[12:], b.DupPackets)
+	binary.BigEndian.PutUint32(contents[16:], b.MinJitter)
+	binary.BigEndian.PutUint32(contents[20:], b.MaxJitter)
+	binary.BigEndian.PutUint32(contents[24:], b.MeanJitter)
+	binary.BigEndian.PutUint32(contents[28:], b.DevJitter)
+	contents[32] = b.MinTTLOrHL
+	contents[33] = b.MaxTTLOrHL
+	contents[34] = b.MeanTTLOrHL
+	contents[35] = b.DevTTLOrHL
+
+	return typeSpecific, contents, nil
+}
+
+func (b *XRStatisticsSummaryBlock) unmarshal(typeSpecific byte, contents []byte) error {
+	if len(contents) != xrStatisticsSize {
+		return errors.New("rtcp: XR statistics summary block wrong length")
+	}
+
+	b.LossReports = typeSpecific&0x80 > 0
+	b.DupReports = typeSpecific&0x40 > 0
+	b.JitterReports = typeSpecific&0x20 > 0
+	b.ToH = (typeSpecific >> 3) & 0x03
+
+	b.SSRC = binary.BigEndian.Uint32(contents[0:])
+	b.BeginSeq = binary.BigEndian.Uint16(contents[4:])
+	b.EndSeq = binary.BigEndian.Uint16(contents[6:])
+	b.LostPackets = binary.BigEndian.Uint32(contents[8:])
+	b.DupPackets = binary.BigEndian.Uint32(contents[12:])
+	b.MinJitter = binary.BigEndian.Uint32(contents[16:])
+	b.MaxJitter = binary.BigEndian.Uint32(contents[20:])
+	b.MeanJitter = binary.BigEndian.Uint32(contents[24:])
+	b.DevJitter = binary.BigEndian.Uint32(contents[28:])
+	b.MinTTLOrHL = contents[32]
+	b.MaxTTLOrHL = contents[33]
+	b.MeanTTLOrHL = contents[34]
+	b.DevTTLOrHL = contents[35]
+
+	return nil
+}
+
+// XRVoIPMetricsBlock carries the call quality metrics of
+// https://tools.ietf.org/html/rfc3611#section-4.7
+type XRVoIPMetricsBlock struct {
+	SSRC           uint32
+	LossRate       uint8
+	DiscardRate    uint8
+	BurstDensity   uint8
+	GapDensity     uint8
+	BurstDuration  uint16
+	GapDuration    uint16
+	RoundTripDelay uint16
+	EndSystemDelay uint16
+	SignalLevel    int8
+	NoiseLevel     int8
+	RERL           uint8
+	Gmin           uint8
+	RFactor        uint8
+	ExtRFactor     uint8
+	MOSLQ          uint8
+	MOSCQ          uint8
+	RXConfig       uint8
+	JBNominal      uint16
+	JBMaximum      uint16
+	JBAbsMax       uint16
+}
+
+func (b *XRVoIPMetricsBlock) BlockType() uint8 {
+	return XRVoIPMetrics
+}
+
+func (b *XRVoIPMetricsBlock) marshal() (byte, []byte, error) {
+	contents := make([]byte, xrVoIPMetricsSize)
+	binary.BigEndian.PutUint32(contents[0:], b.SSRC)
+	contents[4] = b.LossRate
+	contents[5] = b.DiscardRate
+	contents[6] = b.BurstDensity
+	contents[7] = b.GapDensity
+	binary.BigEndian.PutUint16(contents[8:], b.BurstDuration)
+	binary.BigEndian.PutUint16(contents[10:], b.GapDuration)
+	binary.BigEndian.PutUint16(contents[12:], b.RoundTripDelay)
+	binary.BigEndian.PutUint16(contents[14:], b.EndSystemDelay)
+	contents[16] = byte(b.SignalLevel)
+	contents[17] = byte(b.NoiseLevel)
+	contents[18] = b.RERL
+	contents[19] = b.Gmin
+	contents[20] = b.RFactor
+	contents[21] = b.ExtRFactor
+	contents[22] = b.MOSLQ
+	contents[23] = b.MOSCQ
+	contents[24] = b.RXConfig
+	// contents[25] is reserved
+	binary.BigEndian.PutUint16(contents[26:], b.JBNominal)
+	binary.BigEndian.PutUint16(contents[28:], b.JBMaximum)
+	binary.BigEndian.PutUint16(contents[30:], b.JBAbsMax)
+
+	return 0, contents, nil
+}
+
+func (b *XRVoIPMetricsBlock) unmarshal(typeSpecific byte, contents []byte) error {
+	if len(contents) != xrVoIPMetricsSize {
+		return errors.New("rtcp: XR VoIP metrics block wrong length")
+	}
+
+	b.SSRC = binary.BigEndian.Uint32(contents[0:])
+	b.LossRate = contents[4]
+	b.DiscardRate = contents[5]
+	b.BurstDensity = contents[6]
+	b.GapDensity = contents[7]
+	b.BurstDuration = binary.BigEndian.Uint16(contents[8:])
+	b.GapDuration = binary.BigEndian.Uint16(contents[10:])
+	b.RoundTripDelay = binary.BigEndian.Uint16(contents[12:])
+	b.EndSystemDelay = binary.BigEndian.Uint16(contents[14:])
+	b.SignalLevel = int8(contents[16])
+	b.NoiseLevel = int8(contents[17])
+	b.RERL = contents[18]
+	b.Gmin = contents[19]
+	b.RFactor = contents[20]
+	b.ExtRFactor = contents[21]
+	b.MOSLQ = contents[22]
+	b.MOSCQ = contents[23]
+	b.RXConfig = contents[24]
+	b.JBNominal = binary.BigEndian.Uint16(contents[26:])
+	b.JBMaximum = binary.BigEndian.Uint16(contents[28:])
+	b.JBAbsMax = binary.BigEndian.Uint16(contents[30:])
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package rtp
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestExtendedReport(t *testing.T) {
+	xr := &ExtendedReport{
+		SSRC: 0x11223344,
+		Blocks: []XRBlock{
+			&XRReceiverRefTimeBlock{NTPTime: 0xe2a6f3b7c0000000},
+			&XRDLRRBlock{Reports: []DLRRReport{{SSRC: 0x55667788, LRR: 0xf3b7c000, DLRR: 0x8000}}},
+			&XRLossRLEBlock{xrRLE{Thinning: 2, SSRC: 0x55667788, BeginSeq: 10, EndSeq: 30, Chunks: []uint16{0x4014}}},
+		},
+	}
+
+	data, err := xr.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	golden, _ := hex.DecodeString("80cf000c" + "11223344" +
+		"04000002" + "e2a6f3b7c0000000" +
+		"05000003" + "55667788" + "f3b7c000" + "00008000" +
+		"01020003" + "55667788" + "000a001e" + "40140000")
+	compareByteArrays(t, data, golden)
+
+	var xr2 ExtendedReport
+	err = xr2.Unmarshal(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, xr2.SSRC, uint32(0x11223344))
+	assertEqual(t, len(xr2.Blocks), 3)
+
+	rrt, ok := xr2.Blocks[0].(*XRReceiverRefTimeBlock)
+	if !ok {
+		t.Fatalf("Receiver reference time block not parsed")
+	}
+	assertEqual(t, rrt.NTPTime, uint64(0xe2a6f3b7c0000000))
+
+	dlrr, ok := xr2.Blocks[1].(*XRDLRRBlock)
+	if !ok {
+		t.Fatalf("DLRR block not parsed")
+	}
+	assertEqual(t, dlrr.Reports[0], DLRRReport{SSRC: 0x55667788, LRR: 0xf3b7c000, DLRR: 0x8000})
+
+	loss, ok := xr2.Blocks[2].(*XRLossRLEBlock)
+	if !ok {
+		t.Fatalf("Loss RLE block not parsed")
+	}
+	assertEqual(t, loss.Thinning, uint8(2))
+	assertEqual(t, loss.EndSeq, uint16(30))
+	assertEqual(t, len(loss.Chunks), 1)
+	assertEqual(t, loss.Chunks[0], uint16(0x4014))
+}
+
+func TestExtendedReportSummaries(t *testing.T) {
+	stats := &XRStatisticsSummaryBlock{
+		LossReports:   true,
+		JitterReports: true,
+		ToH:           XRToHIPv4,
+		SSRC:          2,
+		BeginSeq:      1,
+		EndSeq:        100,
+		LostPackets:   3,
+		MinJitter:     10,
+		MaxJitter:     90,
+		MeanJitter:    40,
+		DevJitter:     5,
+		MinTTLOrHL:    60,
+		MaxTTLOrHL:    64,
+		MeanTTLOrHL:   62,
+	}
+	voip := &XRVoIPMetricsBlock{
+		SSRC:           2,
+		LossRate:       12,
+		BurstDuration:  200,
+		RoundTripDelay: 150,
+		SignalLevel:    -20,
+		NoiseLevel:     -70,
+		RERL:           127,
+		Gmin:           16,
+		RFactor:        85,
+		ExtRFactor:     127,
+		MOSLQ:          41,
+		MOSCQ:          40,
+		RXConfig:       0x80,
+		JBNominal:      40,
+		JBMaximum:      80,
+		JBAbsMax:       200,
+	}
+	dup := &XRDuplicateRLEBlock{xrRLE{SSRC: 2, BeginSeq: 1, EndSeq: 3, Chunks: []uint16{0x8000, 0x0002, 0x4001}}}
+	unknown := &XRUnknownBlock{Type: 42, TypeSpecific: 7, Contents: []byte{1, 2, 3, 4}}
+
+	xr := &ExtendedReport{SSRC: 1, Blocks: []XRBlock{stats, voip, dup, unknown}}
+	data, err := xr.Marshal()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(data), 8+40+36+20+8)
+
+	var xr2 ExtendedReport
+	err = xr2.Unmarshal(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(xr2.Blocks), 4)
+	assertEqual(t, *xr2.Blocks[0].(*XRStatisticsSummaryBlock), *stats)
+	assertEqual(t, *xr2.Blocks[1].(*XRVoIPMetricsBlock), *voip)
+	assertEqual(t, len(xr2.Blocks[2].(*XRDuplicateRLEBlock).Chunks), 3)
+	unknown2 := xr2.Blocks[3].(*XRUnknownBlock)
+	assertEqual(t, unknown2.Type, uint8(42))
+	assertEqual(t, unknown2.TypeSpecific, byte(7))
+	compareByteArrays(t, unknown2.Contents, unknown.Contents)
+
+	// block length past the end of the packet
+	data[rtcpHeaderSize+3] = 20
+	err = xr2.Unmarshal(data)
+	if err == nil {
+		t.Errorf("XR with bad block length was accepted")
+	}
+
+	// the reserved ToH is not sent
+	stats.ToH = 3
+	_, err = xr.Marshal()
+	if err == nil {
+		t.Errorf("XR with reserved ToH was marshaled")
+	}
+}
+
+func TestExtendedReportCompound(t *testing.T) {
+	rr := &ReceiverReport{SSRC: 1}
+	sdes := &SourceDescription{Chunks: []SDESChunk{{SSRC: 1, Items: []SDESItem{{Type: SDESCNAME, Text: "a"}}}}}
+	xr := &ExtendedReport{SSRC: 1, Blocks: []XRBlock{&XRReceiverRefTimeBlock{NTPTime: 5}}}
+
+	data, err := MarshalRTCPCompound([]RTCPMessage{rr, sdes, xr})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	p, _ := NewRTCPCompoundPacket(data, 0)
+	msgs, err := p.GetMessages()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if _, ok := msgs[2].(*ExtendedReport); !ok {
+		t.Errorf("XR not parsed")
+	}
+}
@@ -4,138 +4,688 @@ package rtp
 Header extentions described in https://tools.ietf.org/html/rfc8285
 
 Client To Mixer volume level in https://tools.ietf.org/html/rfc6464
+
+Mixer To Client volume levels in https://tools.ietf.org/html/rfc6465
+
+Absolute send time in https://webrtc.googlesource.com/src/+/main/docs/native-code/rtp-hdrext/abs-send-time
+
+Transport wide sequence number in https://tools.ietf.org/html/draft-holmer-rmcat-transport-wide-cc-extensions-01
+
+MID in https://tools.ietf.org/html/rfc8843#section-15.2
+
+RID and repaired RID in https://tools.ietf.org/html/rfc8852
+
+Typed extentions implement ExtMarshaler and ExtUnmarshaler and are bound to
+their URI with RTPSession.RegisterExt
 */
 
 import (
+	"encoding/binary"
 	"errors"
-	"fmt"
+	"reflect"
+	"time"
 )
 
-func (p *RTPPacket) SetGeneralExt(num int, data []byte) error {
-	// Set a RFC5285 style General Extention
-	// TODO - currently can only set a single extention
-	if (num < 1) || (num == 15) || (num > 255) {
-		return errors.New("rtp: bad extention number for SetGeneralExt")
+const (
+	oneByteExtProfile uint16 = 0xBEDE
+	twoByteExtProfile uint16 = 0x1000 // low 4 bits are appbits
+)
+
+func isTwoByteExtProfile(extNum uint16) bool {
+	return extNum&0xFFF0 == twoByteExtProfile
+}
+
+// hdrExtElement is a single RFC8285 extention element inside a header
+// extention block
+type hdrExtElement struct {
+	id   int
+	data []byte
+}
+
+// parseOneByteExt splits the data of a 0xBEDE header extention into its
+// elements. Padding bytes are skipped and parsing stops at ID 15.
+func parseOneByteExt(ext []byte) ([]hdrExtElement, error) {
+	var elems []hdrExtElement
+
+	for i := 0; i < len(ext); {
+		if ext[i] == 0 { // this is the pad indicator
+			i++
+			continue
+		}
+
+		id := int(ext[i] >> 4)
+		if id == 15 { // stop processing any data after this
+			break
+		}
+
+		extLen := int(ext[i]&0x0F) + 1
+		if i+1+extLen > len(ext) {
+			return nil, errors.New("rtp: one byte header extention element overruns block")
+		}
+
+		elems = append(elems, hdrExtElement{id: id, data: ext[i+1 : i+1+extLen]})
+		i += 1 + extLen
 	}
 
-	if num < 14 {
-		// using 1 byte header
-		if len(data) > 16 {
-			return errors.New("rtp: extention too large for SetGeneralExt short header")
+	return elems, nil
+}
+
+// buildOneByteExt forms the data of a 0xBEDE header extention from elems,
+// zero padded to a multiple of 32 bits
+func buildOneByteExt(elems []hdrExtElement) ([]byte, error) {
+	extLen := 0
+	for _, e := range elems {
+		if (e.id < 1) || (e.id > 14) {
+			return nil, errors.New("rtp: bad extention number for one byte header")
 		}
-		if len(data) < 1 {
-			return errors.New("rtp: extention too small for SetGeneralExt short header")
+		if (len(e.data) < 1) || (len(e.data) > 16) {
+			return nil, errors.New("rtp: bad extention length for one byte header")
 		}
-		lenValue := len(data) - 1
-		header := (num << 4) + lenValue
+		extLen += 1 + len(e.data)
+	}
+	if extLen%4 != 0 {
+		extLen += 4 - extLen%4
+	}
+
+	ext := make([]byte, extLen)
+	offset := 0
+	for _, e := range elems {
+		ext[offset] = byte(e.id<<4) | byte(len(e.data)-1)
+		copy(ext[offset+1:], e.data)
+		offset += 1 + len(e.data)
+	}
+
+	return ext, nil
+}
+
+// parseTwoByteExt splits the data of a 0x100X header extention into its
+// elements. Padding bytes are skipped.
+func parseTwoByteExt(ext []byte) ([]hdrExtElement, error) {
+	var elems []hdrExtElement
 
-		nonPadLen := len(data) + 1
-		pad := 0
-		if nonPadLen%4 != 0 {
-			pad = 4 - nonPadLen%4
+	for i := 0; i < len(ext); {
+		if ext[i] == 0 { // this is the pad indicator
+			i++
+			continue
 		}
 
-		extData := make([]byte, nonPadLen+pad)
-		copy(extData[len(extData)-4:], []byte{0, 0, 0, 0}) // TODO - is this needed or does make do this
-		extData[0] = byte(header)
-		copy(extData[1:], data)
-		extNum := uint16(0xBEDE)
+		if i+2 > len(ext) {
+			return nil, errors.New("rtp: two byte header extention element overruns block")
+		}
+		id := int(ext[i])
+		extLen := int(ext[i+1])
+		if i+2+extLen > len(ext) {
+			return nil, errors.New("rtp: two byte header extention element overruns block")
+		}
 
-		err := p.SetHdrExt(extNum, extData)
-		if err != nil {
-			fmt.Printf("rtp::SetGeneralExt call to SetHdrExt got error %s \n", err.Error())
-			return err
+		elems = append(elems, hdrExtElement{id: id, data: ext[i+2 : i+2+extLen]})
+		i += 2 + extLen
+	}
+
+	return elems, nil
+}
+
+// buildTwoByteExt forms the data of a 0x100X header extention from elems,
+// zero padded to a multiple of 32 bits
+func buildTwoByteExt(elems []hdrExtElement) ([]byte, error) {
+	extLen := 0
+	for _, e := range elems {
+		if (e.id < 1) || (e.id > 255) {
+			return nil, errors.New("rtp: bad extention number for two byte header")
 		}
+		if len(e.data) > 255 {
+			return nil, errors.New("rtp: bad extention length for two byte header")
+		}
+		extLen += 2 + len(e.data)
+	}
+	if extLen%4 != 0 {
+		extLen += 4 - extLen%4
+	}
 
-	} else {
-		// using 2 byte header
-		// TODO
-		return errors.New("rtp SetGeneralExt long header not implemented")
+	ext := make([]byte, extLen)
+	offset := 0
+	for _, e := range elems {
+		ext[offset] = byte(e.id)
+		ext[offset+1] = byte(len(e.data))
+		copy(ext[offset+2:], e.data)
+		offset += 2 + len(e.data)
 	}
 
-	return nil
+	return ext, nil
 }
 
-func (p *RTPPacket) GetGeneralExt(num int) []byte {
-	// Get a RFC5285 style General Extention
-	genExtNum, genExtData := p.GetHdrExt()
+// needsTwoByteExt reports if an element can not be carried in the one
+// byte header form
+func (e *hdrExtElement) needsTwoByteExt() bool {
+	return e.id > 14 || len(e.data) < 1 || len(e.data) > 16
+}
 
-	if genExtNum == 0xBEDE {
-		for {
-			if len(genExtData) == 0 {
-				return nil
-			}
-			extNum := int(genExtData[0] >> 4)
-			extLen := int((genExtData[0] & 0x0F) + 1)
+// getExtElements returns the header extention profile and the RFC8285
+// elements in the packet. A packet with no header extention has a profile
+// of zero and no elements.
+func (p *RTPPacket) getExtElements() (uint16, []hdrExtElement, error) {
+	if !p.GetExtBit() {
+		return 0, nil, nil
+	}
 
-			if len(genExtData) < extLen+1 {
-				return nil
-			}
+	extNum, ext := p.GetHdrExt()
+	if extNum == oneByteExtProfile {
+		elems, err := parseOneByteExt(ext)
+		return extNum, elems, err
+	}
+	if isTwoByteExtProfile(extNum) {
+		elems, err := parseTwoByteExt(ext)
+		return extNum, elems, err
+	}
 
-			if extNum == 0 { //this is the pad indicator
-				genExtData = genExtData[1:len(genExtData)]
-				continue
-			}
+	return 0, nil, errors.New("rtp: packet header extention is not RFC8285 format")
+}
+
+// setExtElements replaces the header extention of the packet with elems,
+// removing it when elems is empty. The one byte form is used unless the
+// packet already uses the two byte form or an element needs it.
+func (p *RTPPacket) setExtElements(extNum uint16, elems []hdrExtElement) error {
+	if len(elems) == 0 {
+		return p.replaceHdrExt(false, 0, nil)
+	}
 
-			if extNum == 15 { // stop processing any data after this
-				return nil
+	if !isTwoByteExtProfile(extNum) {
+		extNum = oneByteExtProfile
+		for i := range elems {
+			if elems[i].needsTwoByteExt() {
+				extNum = twoByteExtProfile
+				break
 			}
+		}
+	}
 
-			//fmt.Printf( "GenExt found num=%d len=%d data=0x%x\n", extNum,extLen, genExtData[1 : extLen+1] )
+	var ext []byte
+	var err error
+	if extNum == oneByteExtProfile {
+		ext, err = buildOneByteExt(elems)
+	} else {
+		ext, err = buildTwoByteExt(elems)
+	}
+	if err != nil {
+		return err
+	}
 
-			if extNum == num {
-				data := genExtData[1 : extLen+1]
-				return data
-			}
+	return p.replaceHdrExt(true, extNum, ext)
+}
+
+// replaceHdrExt is like SetHdrExt but keeps the payload and padding that
+// follow the header extention. If present is false, the header extention is
+// removed.
+func (p *RTPPacket) replaceHdrExt(present bool, extNum uint16, ext []byte) error {
+	offset := p.getHdrExtOffset()
+	oldEnd := p.getPayloadOffset()
+	restLen := len(p.buffer) - oldEnd
+
+	newEnd := offset
+	if present {
+		newEnd += 4 + len(ext)
+	}
+	packetLen := newEnd + restLen
 
-			genExtData = genExtData[extLen+1 : len(genExtData)] // move to next extention
+	if packetLen > cap(p.buffer) {
+		grow := packetLen - cap(p.buffer)
+		p.buffer = append(p.buffer[:cap(p.buffer)], make([]byte, grow)...)
+	}
+	if packetLen > len(p.buffer) {
+		p.buffer = p.buffer[0:packetLen]
+	}
+	copy(p.buffer[newEnd:packetLen], p.buffer[oldEnd:oldEnd+restLen])
+	p.buffer = p.buffer[0:packetLen]
+
+	if !present {
+		return p.SetExtBit(false)
+	}
+
+	err := p.SetExtBit(true)
+	if err != nil {
+		return err
+	}
+
+	binary.BigEndian.PutUint16(p.buffer[offset:], extNum)
+	binary.BigEndian.PutUint16(p.buffer[offset+2:], uint16(len(ext)/4))
+	copy(p.buffer[offset+4:newEnd], ext)
+
+	return nil
+}
+
+// SetGeneralExt sets a RFC8285 style general extention. Any other
+// extentions already in the packet are kept and an existing extention with
+// the same number is replaced. The payload is kept. The packet is switched
+// to the two byte header form if num is above 14 or data is empty or longer
+// than 16 bytes.
+func (p *RTPPacket) SetGeneralExt(num int, data []byte) error {
+	if (num < 1) || (num > 255) {
+		return errors.New("rtp: bad extention number for SetGeneralExt")
+	}
+
+	if len(data) > 255 {
+		return errors.New("rtp: extention too large for SetGeneralExt")
+	}
+
+	extNum, elems, err := p.getExtElements()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i := range elems {
+		if elems[i].id == num {
+			elems[i].data = data
+			found = true
 		}
 	}
-	if genExtNum&0xFFF0 == 0x1000 {
-		// TODO - 2byte extention
+	if !found {
+		elems = append(elems, hdrExtElement{id: num, data: data})
+	}
+
+	return p.setExtElements(extNum, elems)
+}
+
+// RemoveGeneralExt removes a RFC8285 style general extention and keeps any
+// others. The header extention is dropped once it has no extentions left.
+func (p *RTPPacket) RemoveGeneralExt(num int) error {
+	extNum, elems, err := p.getExtElements()
+	if err != nil {
+		return err
+	}
+
+	kept := elems[:0]
+	for _, e := range elems {
+		if e.id != num {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == len(elems) {
+		return nil
+	}
+
+	return p.setExtElements(extNum, kept)
+}
+
+// GetGeneralExt gets a RFC8285 style general extention. It returns nil if
+// the extention is not in the packet.
+func (p *RTPPacket) GetGeneralExt(num int) []byte {
+	_, elems, err := p.getExtElements()
+	if err != nil {
+		return nil
+	}
+
+	for _, e := range elems {
+		if e.id == num {
+			return e.data
+		}
 	}
 
 	// Gen Ext not found
 	return nil
 }
 
-func (p *RTPPacket) SetExtClientVolume(s *RTPSession, vad bool, dBov int8) error {
-	// Set a RFC6464 client to mixer volume level
-	extNum := s.extNameMap["urn:ietf:params:rtp-hdrext:ssrc-audio-level"]
-	if extNum <= 14 {
-		data := make([]byte, 1)
-		value := uint8(-dBov)
-		if vad {
-			value |= 0x80
-		}
-		data[0] = value
-		err := p.SetGeneralExt(extNum, data)
+// ExtMarshaler is implemented by typed header extention values that can be
+// written with SetExt
+type ExtMarshaler interface {
+	MarshalExt() ([]byte, error)
+}
+
+// ExtUnmarshaler is implemented by pointers to typed header extention values
+// that can be read with GetExt
+type ExtUnmarshaler interface {
+	UnmarshalExt(data []byte) error
+}
+
+var extUnmarshalerType = reflect.TypeOf((*ExtUnmarshaler)(nil)).Elem()
+
+// extType returns the type a header extention value is registered under.
+// Values and pointers to values map to the same type.
+func extType(value interface{}) reflect.Type {
+	t := reflect.TypeOf(value)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// SetExt sets a typed header extention. The type of value must have been
+// registered with RTPSession.RegisterExt and its URI mapped with SetExtMap.
+func (p *RTPPacket) SetExt(s *RTPSession, value ExtMarshaler) error {
+	extNum, err := s.getExtNum(value)
+	if err != nil {
+		return err
+	}
+	if extNum == 0 {
+		return errors.New("rtp: no extmap for extention in SetExt")
+	}
+
+	data, err := value.MarshalExt()
+	if err != nil {
 		return err
-	} else if extNum <= 255 {
-		// TODO
-		return errors.New("rtp SetGeneralExt long header not implemented")
 	}
 
-	return errors.New("rtp: extention number out or range in SetExtClientVolume")
+	return p.SetGeneralExt(extNum, data)
 }
 
-func (p *RTPPacket) GetExtClientVolume(s *RTPSession) (vad bool, dBov int8) {
-	// Get a RFC6464 client to mixer volume level
-	extNum := s.extNameMap["urn:ietf:params:rtp-hdrext:ssrc-audio-level"]
+// GetExt gets a typed header extention into value, which must be a pointer
+// to a type registered with RTPSession.RegisterExt. It returns false if the
+// extention is not in the packet or its URI is not mapped.
+func (p *RTPPacket) GetExt(s *RTPSession, value ExtUnmarshaler) (bool, error) {
+	extNum, err := s.getExtNum(value)
+	if err != nil {
+		return false, err
+	}
+	if extNum == 0 {
+		return false, nil
+	}
 
 	data := p.GetGeneralExt(extNum)
+	if data == nil {
+		return false, nil
+	}
+
+	err = value.UnmarshalExt(data)
+	if err != nil {
+		return false, err
+	}
 
-	if extNum <= 14 {
-		if len(data) == 1 {
+	return true, nil
+}
 
-			dBov = -int8(data[0] & 0x7F)
-			vad = data[0]&0x7F > 0
-			return
+// ExtClientVolume is a RFC6464 client to mixer audio level. Level is in dBov
+// from 0 down to -127.
+type ExtClientVolume struct {
+	VAD   bool
+	Level int8
+}
+
+func (v ExtClientVolume) MarshalExt() ([]byte, error) {
+	if (v.Level > 0) || (v.Level < -127) {
+		return nil, errors.New("rtp: client volume level out of range")
+	}
+
+	value := uint8(-v.Level)
+	if v.VAD {
+		value |= 0x80
+	}
+
+	return []byte{value}, nil
+}
+
+func (v *ExtClientVolume) UnmarshalExt(data []byte) error {
+	if len(data) < 1 {
+		return errors.New("rtp: client volume extention too short")
+	}
+
+	v.VAD = data[0]&0x80 > 0
+	v.Level = -int8(data[0] & 0x7F)
+
+	return nil
+}
+
+// ExtMixerVolume is a RFC6465 mixer to client audio level list with one
+// level per CSRC in CSRC order. Levels are in dBov from 0 down to -127.
+type ExtMixerVolume []int8
+
+func (v ExtMixerVolume) MarshalExt() ([]byte, error) {
+	if (len(v) < 1) || (len(v) > 15) {
+		return nil, errors.New("rtp: mixer volume needs 1 to 15 levels")
+	}
+
+	data := make([]byte, len(v))
+	for i, level := range v {
+		if (level > 0) || (level < -127) {
+			return nil, errors.New("rtp: mixer volume level out of range")
 		}
-	} else if extNum <= 255 {
-		// TODO
+		data[i] = uint8(-level) // top bit is reserved and left 0
+	}
+
+	return data, nil
+}
+
+func (v *ExtMixerVolume) UnmarshalExt(data []byte) error {
+	levels := make([]int8, len(data))
+	for i, b := range data {
+		levels[i] = -int8(b & 0x7F)
+	}
+	*v = levels
+
+	return nil
+}
+
+func (p *RTPPacket) SetExtMixerVolume(s *RTPSession, levels []int8) error {
+	// Set RFC6465 mixer to client volume levels, call after SetCSRC
+	if len(levels) != p.GetCC() {
+		return errors.New("rtp: mixer volume level count does not match CSRC count")
+	}
+
+	return p.SetExt(s, ExtMixerVolume(levels))
+}
+
+func (p *RTPPacket) GetExtMixerVolume(s *RTPSession) ([]int8, bool) {
+	// Get RFC6465 mixer to client volume levels
+	var v ExtMixerVolume
+
+	ok, err := p.GetExt(s, &v)
+	if !ok || err != nil {
+		return nil, false
+	}
+
+	if len(v) != p.GetCC() {
+		return nil, false
+	}
+
+	return v, true
+}
+
+func (p *RTPPacket) SetExtClientVolume(s *RTPSession, vad bool, dBov int8) error {
+	// Set a RFC6464 client to mixer volume level
+	return p.SetExt(s, ExtClientVolume{VAD: vad, Level: dBov})
+}
+
+// GetExtClientVolume gets a RFC6464 client to mixer volume level. ok is
+// false if the packet does not carry the extention, which is distinct from a
+// silent packet with a level of -127 dBov.
+func (p *RTPPacket) GetExtClientVolume(s *RTPSession) (dBov int8, vad bool, ok bool) {
+	var v ExtClientVolume
+
+	found, err := p.GetExt(s, &v)
+	if !found || err != nil {
+		return 0, false, false
+	}
+
+	return v.Level, v.VAD, true
+}
+
+// ntpEpochOffset is the number of seconds from the NTP epoch in 1900 to the
+// unix epoch in 1970
+const ntpEpochOffset = 2208988800
+
+// toNTP converts t to a 64 bit NTP timestamp in 32.32 fixed point seconds
+func toNTP(t time.Time) uint64 {
+	secs := uint64(t.Unix() + ntpEpochOffset)
+	frac := (uint64(t.Nanosecond()) << 32) / 1e9
+	return secs<<32 | frac
+}
+
+// fromNTP converts a 64 bit NTP timestamp to a time
+func fromNTP(ntp uint64) time.Time {
+	secs := int64(ntp>>32) - ntpEpochOffset
+	nsec := ((ntp & 0xFFFFFFFF) * 1e9) >> 32
+	return time.Unix(secs, int64(nsec))
+}
+
+// ExtAbsSendTime is the abs-send-time extention. It holds the low 6 bits of
+// the NTP seconds and 18 bits of fraction.
+type ExtAbsSendTime uint32
+
+// AbsSendTime returns the abs-send-time value for t
+func AbsSendTime(t time.Time) ExtAbsSendTime {
+	return ExtAbsSendTime((toNTP(t) >> 14) & 0xFFFFFF)
+}
+
+// Duration returns the time since the start of the 64 second period
+func (v ExtAbsSendTime) Duration() time.Duration {
+	return time.Duration((uint64(v) * uint64(time.Second)) >> 18)
+}
+
+func (v ExtAbsSendTime) MarshalExt() ([]byte, error) {
+	if v > 0xFFFFFF {
+		return nil, errors.New("rtp: abs send time larger than 24 bits")
+	}
+
+	return []byte{byte(v >> 16), byte(v >> 8), byte(v)}, nil
+}
+
+func (v *ExtAbsSendTime) UnmarshalExt(data []byte) error {
+	if len(data) != 3 {
+		return errors.New("rtp: abs send time extention wrong length")
+	}
+
+	*v = ExtAbsSendTime(data[0])<<16 | ExtAbsSendTime(data[1])<<8 | ExtAbsSendTime(data[2])
+
+	return nil
+}
+
+func (p *RTPPacket) SetExtAbsSendTime(s *RTPSession, t time.Time) error {
+	return p.SetExt(s, AbsSendTime(t))
+}
+
+func (p *RTPPacket) GetExtAbsSendTime(s *RTPSession) (ExtAbsSendTime, bool) {
+	var v ExtAbsSendTime
+
+	ok, err := p.GetExt(s, &v)
+	if !ok || err != nil {
+		return 0, false
+	}
+
+	return v, true
+}
+
+// ExtTransportSeq is the transport wide sequence number used for transport
+// wide congestion control
+type ExtTransportSeq uint16
+
+func (v ExtTransportSeq) MarshalExt() ([]byte, error) {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, uint16(v))
+	return data, nil
+}
+
+func (v *ExtTransportSeq) UnmarshalExt(data []byte) error {
+	if len(data) != 2 {
+		return errors.New("rtp: transport sequence number extention wrong length")
+	}
+
+	*v = ExtTransportSeq(binary.BigEndian.Uint16(data))
+
+	return nil
+}
+
+func (p *RTPPacket) SetExtTransportSeq(s *RTPSession, seq uint16) error {
+	return p.SetExt(s, ExtTransportSeq(seq))
+}
+
+func (p *RTPPacket) GetExtTransportSeq(s *RTPSession) (uint16, bool) {
+	var v ExtTransportSeq
+
+	ok, err := p.GetExt(s, &v)
+	if !ok || err != nil {
+		return 0, false
+	}
+
+	return uint16(v), true
+}
+
+// marshalSDESExt checks a SDES string extention fits in a header extention
+// element
+func marshalSDESExt(v string) ([]byte, error) {
+	if len(v) > 255 {
+		return nil, errors.New("rtp: SDES extention longer than 255 bytes")
+	}
+
+	return []byte(v), nil
+}
+
+// ExtMID is the media identification used to demultiplex BUNDLE
+type ExtMID string
+
+func (v ExtMID) MarshalExt() ([]byte, error) {
+	return marshalSDESExt(string(v))
+}
+
+func (v *ExtMID) UnmarshalExt(data []byte) error {
+	*v = ExtMID(data)
+	return nil
+}
+
+// ExtRID is the RTP stream identifier used to demultiplex simulcast
+type ExtRID string
+
+func (v ExtRID) MarshalExt() ([]byte, error) {
+	return marshalSDESExt(string(v))
+}
+
+func (v *ExtRID) UnmarshalExt(data []byte) error {
+	*v = ExtRID(data)
+	return nil
+}
+
+// ExtRepairedRID is the RTP stream identifier of the stream that a
+// redundancy stream repairs
+type ExtRepairedRID string
+
+func (v ExtRepairedRID) MarshalExt() ([]byte, error) {
+	return marshalSDESExt(string(v))
+}
+
+func (v *ExtRepairedRID) UnmarshalExt(data []byte) error {
+	*v = ExtRepairedRID(data)
+	return nil
+}
+
+func (p *RTPPacket) SetExtMID(s *RTPSession, mid string) error {
+	return p.SetExt(s, ExtMID(mid))
+}
+
+func (p *RTPPacket) GetExtMID(s *RTPSession) (string, bool) {
+	var v ExtMID
+
+	ok, err := p.GetExt(s, &v)
+	if !ok || err != nil {
+		return "", false
+	}
+
+	return string(v), true
+}
+
+func (p *RTPPacket) SetExtRID(s *RTPSession, rid string) error {
+	return p.SetExt(s, ExtRID(rid))
+}
+
+func (p *RTPPacket) GetExtRID(s *RTPSession) (string, bool) {
+	var v ExtRID
+
+	ok, err := p.GetExt(s, &v)
+	if !ok || err != nil {
+		return "", false
+	}
+
+	return string(v), true
+}
+
+func (p *RTPPacket) SetExtRepairedRID(s *RTPSession, rid string) error {
+	return p.SetExt(s, ExtRepairedRID(rid))
+}
+
+func (p *RTPPacket) GetExtRepairedRID(s *RTPSession) (string, bool) {
+	var v ExtRepairedRID
 
+	ok, err := p.GetExt(s, &v)
+	if !ok || err != nil {
+		return "", false
 	}
 
-	return false, 0
+	return string(v), true
 }
@@ -1,8 +1,10 @@
 package rtp
 
 import (
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestGenExt(t *testing.T) {
@@ -131,7 +133,10 @@ func TestClientVolume(t *testing.T) {
 			t.Errorf(err.Error())
 		}
 
-		vad, dBov := p.GetExtClientVolume(s)
+		dBov, vad, ok := p.GetExtClientVolume(s)
+		if ok != true {
+			t.Errorf("Client volume not found")
+		}
 		if vad != true {
 			t.Errorf("Vad bit is wrong")
 		}
@@ -140,3 +145,563 @@ func TestClientVolume(t *testing.T) {
 		}
 	}
 }
+
+func TestSetMultiGenExt(t *testing.T) {
+	p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 22 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+
+	err := p.SetPayload([]byte{200, 11, 12, 13})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	err = p.SetGeneralExt(1, []byte{0x81})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	err = p.SetGeneralExt(3, []byte{1, 2, 3})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	err = p.SetGeneralExt(14, []byte{0xA, 0xB})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	// 1+1 + 1+3 + 1+2 = 9 bytes of extentions padded to 12
+	assertEqual(t, p.GetHdrExtLen(), 16)
+	compareByteArrays(t, p.GetGeneralExt(1), []byte{0x81})
+	compareByteArrays(t, p.GetGeneralExt(3), []byte{1, 2, 3})
+	compareByteArrays(t, p.GetGeneralExt(14), []byte{0xA, 0xB})
+	compareByteArrays(t, p.GetPayload(), []byte{200, 11, 12, 13})
+
+	// replace with a longer value keeps the others
+	err = p.SetGeneralExt(3, []byte{4, 5, 6, 7, 8, 9, 10, 11})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	assertEqual(t, p.GetHdrExtLen(), 20)
+	compareByteArrays(t, p.GetGeneralExt(1), []byte{0x81})
+	compareByteArrays(t, p.GetGeneralExt(3), []byte{4, 5, 6, 7, 8, 9, 10, 11})
+	compareByteArrays(t, p.GetGeneralExt(14), []byte{0xA, 0xB})
+	compareByteArrays(t, p.GetPayload(), []byte{200, 11, 12, 13})
+
+	err = p.RemoveGeneralExt(3)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	assertEqual(t, p.GetHdrExtLen(), 12)
+	if p.GetGeneralExt(3) != nil {
+		t.Errorf("Removed extention still present")
+	}
+	compareByteArrays(t, p.GetGeneralExt(14), []byte{0xA, 0xB})
+	compareByteArrays(t, p.GetPayload(), []byte{200, 11, 12, 13})
+
+	err = p.RemoveGeneralExt(1)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	err = p.RemoveGeneralExt(14)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	assertEqual(t, p.GetExtBit(), false)
+	assertEqual(t, len(p.buffer), 16)
+	compareByteArrays(t, p.GetPayload(), []byte{200, 11, 12, 13})
+}
+
+func TestSetGenExtPadded(t *testing.T) {
+	p := NewRTPPacket([]byte{1, 2, 3, 4, 5}, 8 /*pt*/, 22 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+
+	err := p.SetPadding(24)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	err = p.SetGeneralExt(5, []byte{0x11, 0x22})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	compareByteArrays(t, p.GetGeneralExt(5), []byte{0x11, 0x22})
+	compareByteArrays(t, p.GetPayload(), []byte{1, 2, 3, 4, 5})
+	assertEqual(t, len(p.buffer), 32)
+
+	_, err = ParseRTPPacket(p.buffer)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+}
+
+func TestGenExtNotRFC8285(t *testing.T) {
+	p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 22 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+
+	err := p.SetHdrExt(77, []byte{99, 11, 12, 14})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	err = p.SetGeneralExt(1, []byte{1})
+	if err == nil {
+		t.Errorf("SetGeneralExt replaced a non RFC8285 header extention")
+	}
+}
+
+func TestTwoByteGenExt(t *testing.T) {
+	p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 22 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+
+	err := p.SetGeneralExt(1, []byte{0x81})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	extNum, _ := p.GetHdrExt()
+	assertEqual(t, extNum, uint16(0xBEDE))
+
+	// an ID above 14 switches the packet to the two byte form
+	err = p.SetGeneralExt(200, []byte{1, 2, 3})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	extNum, ext := p.GetHdrExt()
+	assertEqual(t, extNum, uint16(0x1000))
+	compareByteArrays(t, ext, []byte{1, 1, 0x81, 200, 3, 1, 2, 3})
+	compareByteArrays(t, p.GetGeneralExt(1), []byte{0x81})
+	compareByteArrays(t, p.GetGeneralExt(200), []byte{1, 2, 3})
+	compareByteArrays(t, p.GetPayload(), []byte{1, 2, 3, 4})
+
+	// the two byte form is kept even once it is no longer needed
+	err = p.RemoveGeneralExt(200)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	extNum, _ = p.GetHdrExt()
+	assertEqual(t, extNum, uint16(0x1000))
+	compareByteArrays(t, p.GetGeneralExt(1), []byte{0x81})
+
+	// zero length elements are allowed in the two byte form
+	err = p.SetGeneralExt(15, []byte{})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	ext15 := p.GetGeneralExt(15)
+	if ext15 == nil || len(ext15) != 0 {
+		t.Errorf("Zero length extention is wrong")
+	}
+
+	long := make([]byte, 255)
+	long[254] = 0xFE
+	err = p.SetGeneralExt(7, long)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	compareByteArrays(t, p.GetGeneralExt(7), long)
+
+	err = p.SetGeneralExt(7, make([]byte, 256))
+	if err == nil {
+		t.Errorf("Extention longer than 255 bytes was accepted")
+	}
+
+	_, err = ParseRTPPacket(p.buffer)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+}
+
+func TestTwoByteGenExtLongData(t *testing.T) {
+	p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 22 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+
+	err := p.SetGeneralExt(2, []byte{5})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	// data longer than 16 bytes does not fit the one byte form
+	err = p.SetGeneralExt(3, []byte("0123456789abcdefg"))
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	extNum, _ := p.GetHdrExt()
+	assertEqual(t, extNum, uint16(0x1000))
+	compareByteArrays(t, p.GetGeneralExt(2), []byte{5})
+	compareByteArrays(t, p.GetGeneralExt(3), []byte("0123456789abcdefg"))
+}
+
+func TestTwoByteGenExtParse(t *testing.T) {
+	p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 22 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+
+	// example from section 4.3 of rfc8285 with appbits set
+	err := p.SetHdrExt(0x1003, []byte{0x1, 0x0, 0x2, 0x1, 0x42, 0x0, 0x3, 0x4, 0x1, 0x2, 0x3, 0x4})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	if ext := p.GetGeneralExt(1); ext == nil || len(ext) != 0 {
+		t.Errorf("Problem with zero length two byte extention")
+	}
+	compareByteArrays(t, p.GetGeneralExt(2), []byte{0x42})
+	compareByteArrays(t, p.GetGeneralExt(3), []byte{1, 2, 3, 4})
+
+	err = p.SetGeneralExt(2, []byte{0x43})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	extNum, _ := p.GetHdrExt()
+	assertEqual(t, extNum, uint16(0x1003))
+	compareByteArrays(t, p.GetGeneralExt(2), []byte{0x43})
+}
+
+func TestExtMapRange(t *testing.T) {
+	s := NewRTPSession(true)
+
+	err := s.SetExtMap(200, "urn:ietf:params:rtp-hdrext:ssrc-audio-level")
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	err = s.SetExtMap(0, "urn:ietf:params:rtp-hdrext:ssrc-audio-level")
+	if err == nil {
+		t.Errorf("SetExtMap accepted ID 0")
+	}
+
+	err = s.SetExtMap(256, "urn:ietf:params:rtp-hdrext:ssrc-audio-level")
+	if err == nil {
+		t.Errorf("SetExtMap accepted ID 256")
+	}
+
+	p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 22 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+	err = p.SetExtClientVolume(s, true, -12)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	compareByteArrays(t, p.GetGeneralExt(200), []byte{0x8C})
+}
+
+type testFrameMarking struct {
+	start, end bool
+	tid        uint8
+}
+
+func (v testFrameMarking) MarshalExt() ([]byte, error) {
+	var b byte = v.tid & 0x07
+	if v.start {
+		b |= 0x80
+	}
+	if v.end {
+		b |= 0x40
+	}
+	return []byte{b}, nil
+}
+
+func (v *testFrameMarking) UnmarshalExt(data []byte) error {
+	if len(data) < 1 {
+		return errors.New("frame marking too short")
+	}
+	v.start = data[0]&0x80 > 0
+	v.end = data[0]&0x40 > 0
+	v.tid = data[0] & 0x07
+	return nil
+}
+
+func TestExtRegistry(t *testing.T) {
+	s := NewRTPSession(true)
+
+	err := s.RegisterExt("urn:ietf:params:rtp-hdrext:framemarking", &testFrameMarking{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	err = s.RegisterExt("urn:example:other", testFrameMarking{})
+	if err == nil {
+		t.Errorf("Type registered for two URIs")
+	}
+
+	p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 22 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+
+	err = p.SetExt(s, testFrameMarking{start: true, tid: 3})
+	if err == nil {
+		t.Errorf("SetExt with no extmap did not fail")
+	}
+
+	var fm testFrameMarking
+	ok, err := p.GetExt(s, &fm)
+	if ok || err != nil {
+		t.Errorf("GetExt with no extmap found extention")
+	}
+
+	err = s.SetExtMap(4, "urn:ietf:params:rtp-hdrext:framemarking")
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	err = s.SetExtMap(5, ExtURIClientVolume)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	ok, err = p.GetExt(s, &fm)
+	if ok || err != nil {
+		t.Errorf("GetExt found missing extention")
+	}
+
+	err = p.SetExt(s, testFrameMarking{start: true, tid: 3})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	err = p.SetExt(s, ExtClientVolume{VAD: true, Level: -30})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	ok, err = p.GetExt(s, &fm)
+	if !ok || err != nil {
+		t.Errorf("GetExt did not find extention")
+	}
+	assertEqual(t, fm, testFrameMarking{start: true, tid: 3})
+
+	var cv ExtClientVolume
+	ok, err = p.GetExt(s, &cv)
+	if !ok || err != nil {
+		t.Errorf("GetExt did not find extention")
+	}
+	assertEqual(t, cv, ExtClientVolume{VAD: true, Level: -30})
+
+	var unknown ExtClientVolume
+	_, err = p.GetExt(NewRTPSession(true), &unknown)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+}
+
+func TestAbsSendTime(t *testing.T) {
+	// 2.5 seconds after a multiple of 64 seconds
+	base := fromNTP(uint64(64*1000) << 32)
+	now := base.Add(2500 * time.Millisecond)
+
+	v := AbsSendTime(now)
+	assertEqual(t, v, ExtAbsSendTime(0x0A0000))
+	assertEqual(t, v.Duration(), 2500*time.Millisecond)
+
+	assertEqual(t, AbsSendTime(base.Add(64*time.Second)), ExtAbsSendTime(0))
+
+	s := NewRTPSession(true)
+	err := s.SetExtMap(3, ExtURIAbsSendTime)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 22 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+	err = p.SetExtAbsSendTime(s, now)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	compareByteArrays(t, p.GetGeneralExt(3), []byte{0x0A, 0, 0})
+
+	got, ok := p.GetExtAbsSendTime(s)
+	assertEqual(t, ok, true)
+	assertEqual(t, got, v)
+
+	_, ok = p.GetExtTransportSeq(s)
+	assertEqual(t, ok, false)
+}
+
+func TestNTP(t *testing.T) {
+	now := time.Unix(1600000000, 250000000)
+	ntp := toNTP(now)
+	assertEqual(t, ntp>>32, uint64(1600000000+2208988800))
+	assertEqual(t, uint32(ntp), uint32(0x40000000))
+	assertEqual(t, fromNTP(ntp).Equal(now), true)
+}
+
+func TestTransportSeq(t *testing.T) {
+	s := NewRTPSession(true)
+	err := s.SetExtMap(5, ExtURITransportSeq)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 22 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+	err = p.SetExtTransportSeq(s, 0xABCD)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	compareByteArrays(t, p.GetGeneralExt(5), []byte{0xAB, 0xCD})
+
+	seq, ok := p.GetExtTransportSeq(s)
+	assertEqual(t, ok, true)
+	assertEqual(t, seq, uint16(0xABCD))
+}
+
+func TestSDESExts(t *testing.T) {
+	s := NewRTPSession(true)
+	s.SetExtMap(1, ExtURIMID)
+	s.SetExtMap(10, ExtURIRID)
+	s.SetExtMap(11, ExtURIRepairedRID)
+
+	p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 22 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+
+	_, ok := p.GetExtMID(s)
+	assertEqual(t, ok, false)
+
+	err := p.SetExtMID(s, "audio")
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	err = p.SetExtRID(s, "hi")
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	err = p.SetExtRepairedRID(s, "lo")
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	extNum, _ := p.GetHdrExt()
+	assertEqual(t, extNum, uint16(0xBEDE))
+
+	mid, ok := p.GetExtMID(s)
+	assertEqual(t, ok, true)
+	assertEqual(t, mid, "audio")
+	rid, ok := p.GetExtRID(s)
+	assertEqual(t, ok, true)
+	assertEqual(t, rid, "hi")
+	rrid, ok := p.GetExtRepairedRID(s)
+	assertEqual(t, ok, true)
+	assertEqual(t, rrid, "lo")
+
+	// a MID longer than 16 bytes needs the two byte form
+	longMID := "0123456789abcdefghij"
+	err = p.SetExtMID(s, longMID)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	extNum, _ = p.GetHdrExt()
+	assertEqual(t, extNum, uint16(0x1000))
+
+	mid, _ = p.GetExtMID(s)
+	assertEqual(t, mid, longMID)
+	rid, _ = p.GetExtRID(s)
+	assertEqual(t, rid, "hi")
+
+	err = p.SetExtRID(s, string(make([]byte, 256)))
+	if err == nil {
+		t.Errorf("RID longer than 255 bytes was accepted")
+	}
+}
+
+func TestMixerVolume(t *testing.T) {
+	s := NewRTPSession(true)
+	err := s.SetExtMap(12, ExtURIMixerVolume)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 22 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+
+	err = p.SetExtMixerVolume(s, []int8{-10})
+	if err == nil {
+		t.Errorf("Mixer volume set without matching CSRC list")
+	}
+
+	err = p.SetCSRC([]uint32{66, 67, 68})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	err = p.SetExtMixerVolume(s, []int8{0, -47, -127})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	compareByteArrays(t, p.GetGeneralExt(12), []byte{0, 47, 127})
+
+	err = p.SetPayload([]byte{200, 11, 12, 13})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	levels, ok := p.GetExtMixerVolume(s)
+	assertEqual(t, ok, true)
+	assertEqual(t, len(levels), 3)
+	assertEqual(t, levels[0], int8(0))
+	assertEqual(t, levels[1], int8(-47))
+	assertEqual(t, levels[2], int8(-127))
+
+	err = p.SetExtMixerVolume(s, []int8{0, 1, -1})
+	if err == nil {
+		t.Errorf("Positive mixer volume level was accepted")
+	}
+
+	// a level list that does not match the CSRC list is ignored
+	err = p.SetGeneralExt(12, []byte{1, 2})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	_, ok = p.GetExtMixerVolume(s)
+	assertEqual(t, ok, false)
+}
+
+func TestClientVolumeRange(t *testing.T) {
+	for _, extNum := range []int{1, 14, 100} {
+		s := NewRTPSession(true)
+		err := s.SetExtMap(extNum, ExtURIClientVolume)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 22 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+
+		_, _, ok := p.GetExtClientVolume(s)
+		if ok {
+			t.Errorf("Missing client volume was found")
+		}
+
+		for level := 0; level <= 127; level++ {
+			for _, vad := range []bool{false, true} {
+				err = p.SetExtClientVolume(s, vad, int8(-level))
+				if err != nil {
+					t.Fatalf(err.Error())
+				}
+
+				dBov, gotVad, ok := p.GetExtClientVolume(s)
+				if !ok || dBov != int8(-level) || gotVad != vad {
+					t.Fatalf("extNum=%d level=%d vad=%t got dBov=%d vad=%t ok=%t", extNum, -level, vad, dBov, gotVad, ok)
+				}
+			}
+		}
+
+		extProfile, _ := p.GetHdrExt()
+		if extNum > 14 {
+			assertEqual(t, extProfile, uint16(0x1000))
+		} else {
+			assertEqual(t, extProfile, uint16(0xBEDE))
+		}
+		compareByteArrays(t, p.GetPayload(), []byte{1, 2, 3, 4})
+	}
+}
+
+func TestClientVolumeVad(t *testing.T) {
+	s := NewRTPSession(true)
+	s.SetExtMap(1, ExtURIClientVolume)
+
+	p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 22 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+
+	// loud packet with the V bit clear is not voice
+	p.SetGeneralExt(1, []byte{0x05})
+	dBov, vad, ok := p.GetExtClientVolume(s)
+	assertEqual(t, ok, true)
+	assertEqual(t, vad, false)
+	assertEqual(t, dBov, int8(-5))
+
+	// the level is -dBov so 127 is silence, and a silent packet with the
+	// V bit set is still voice
+	p.SetGeneralExt(1, []byte{0xFF})
+	dBov, vad, ok = p.GetExtClientVolume(s)
+	assertEqual(t, ok, true)
+	assertEqual(t, vad, true)
+	assertEqual(t, dBov, int8(-127))
+
+	err := p.SetExtClientVolume(s, true, 1)
+	if err == nil {
+		t.Errorf("Positive client volume level was accepted")
+	}
+	err = p.SetExtClientVolume(s, true, -128)
+	if err == nil {
+		t.Errorf("Client volume level below -127 was accepted")
+	}
+}
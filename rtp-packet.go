@@ -25,23 +25,45 @@ Note when creating packets must set CSRC before setting Header extentions before
 
 AES-GCM for SRTP from https://datatracker.ietf.org/doc/rfc7714/
 
+AES-CM with HMAC-SHA1 for SRTP from https://tools.ietf.org/html/rfc3711
+
 */
 
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash"
 )
 
 const (
 	MTU = 1500
+
+	rtpHeaderSize = 12
+	rtpVersion    = 2
+
+	// https://tools.ietf.org/html/rfc7714#section-8.1
+	gcmIVSize = 12
+)
+
+// Errors returned by ParseRTPPacket when a buffer is not a well formed RTP packet
+var (
+	ErrRTPTooShort      = errors.New("rtp: packet too short for RTP header")
+	ErrRTPVersion       = errors.New("rtp: invalid RTP version")
+	ErrRTPCSRCOverflow  = errors.New("rtp: CSRC list larger than packet")
+	ErrRTPHdrExtTooLong = errors.New("rtp: header extention larger than packet")
+	ErrRTPBadPadding    = errors.New("rtp: invalid padding length")
 )
 
 type RTPPacket struct {
-	buffer []byte // contains full RTP packet header, and payload in netwrok byte order
-	ekt    []byte //  contain
+	buffer []byte          // contains full RTP packet header, and payload in netwrok byte order
+	ekt    []byte          //  contain
+	iv     [gcmIVSize]byte // scratch space for the AES-GCM IV
+	cm     cmScratch       // scratch space for AES-CM
 }
 
 func (p *RTPPacket) Clone() *RTPPacket {
@@ -111,7 +133,7 @@ func (p *RTPPacket) SetCC(cc int) error {
 	if (cc < 0) || (cc > 15) {
 		return errors.New("rtp: invalid CC value")
 	}
-	p.buffer[0] = (p.buffer[0] & 0xF) | byte(cc)
+	p.buffer[0] = (p.buffer[0] & 0xF0) | byte(cc)
 	return nil
 }
 
@@ -453,7 +475,10 @@ func (p *RTPPacket) String() string {
 // |       Initialization Vector       |<--+
 // +--+--+--+--+--+--+--+--+--+--+--+--+
 func (p *RTPPacket) gcmIV(roc uint32, salt []byte) []byte {
-	iv := make([]byte, 12)
+	iv := p.iv[:]
+	for i := range iv {
+		iv[i] = 0
+	}
 	iv[2] = p.buffer[8] // SSRC
 	iv[3] = p.buffer[9]
 	iv[4] = p.buffer[10]
@@ -472,19 +497,42 @@ func (p *RTPPacket) gcmIV(roc uint32, salt []byte) []byte {
 	return iv
 }
 
-func (p *RTPPacket) EncryptGCM(roc uint32, key, salt []byte) error {
+// newGCM expands key into an AES-GCM AEAD. Sessions build it once in
+// SetSRTP and use EncryptAEAD and DecryptAEAD for each packet.
+func newGCM(key []byte) (cipher.AEAD, error) {
 	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// EncryptGCM encrypts the packet with a new AEAD for key. Use EncryptAEAD
+// to avoid expanding the key for every packet.
+func (p *RTPPacket) EncryptGCM(roc uint32, key, salt []byte) error {
+	gcm, err := newGCM(key)
 	if err != nil {
 		return err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	return p.EncryptAEAD(gcm, roc, salt)
+}
+
+// DecryptGCM decrypts the packet with a new AEAD for key. Use DecryptAEAD
+// to avoid expanding the key for every packet.
+func (p *RTPPacket) DecryptGCM(roc uint32, key, salt []byte) error {
+	gcm, err := newGCM(key)
 	if err != nil {
 		return err
 	}
 
-	iv := p.gcmIV(roc, salt)
+	return p.DecryptAEAD(gcm, roc, salt)
+}
 
+// EncryptAEAD encrypts the payload in place and appends the tag. It does
+// not allocate when the packet buffer has room for the tag.
+func (p *RTPPacket) EncryptAEAD(gcm cipher.AEAD, roc uint32, salt []byte) error {
 	start := p.getPayloadOffset()
 	end := len(p.buffer)
 
@@ -492,9 +540,9 @@ func (p *RTPPacket) EncryptGCM(roc uint32, key, salt []byte) error {
 		return errors.New("rtp: invalid payload size")
 	}
 
-	tag := make([]byte, gcm.Overhead())
-	p.buffer = append(p.buffer, tag...)
+	p.buffer = append(p.buffer, make([]byte, gcm.Overhead())...)
 
+	iv := p.gcmIV(roc, salt)
 	aad := p.buffer[0:start]
 	pt := p.buffer[start:end]
 
@@ -502,34 +550,219 @@ func (p *RTPPacket) EncryptGCM(roc uint32, key, salt []byte) error {
 	return nil
 }
 
-func (p *RTPPacket) DecryptGCM(roc uint32, key, salt []byte) error {
+// DecryptAEAD checks the tag, decrypts the payload in place and removes
+// the tag
+func (p *RTPPacket) DecryptAEAD(gcm cipher.AEAD, roc uint32, salt []byte) error {
+	start := p.getPayloadOffset()
+	end := len(p.buffer)
+
+	if end-start < gcm.Overhead() {
+		return errors.New("rtp: packet too short for auth tag")
+	}
+
+	iv := p.gcmIV(roc, salt)
+	aad := p.buffer[0:start]
+	ct := p.buffer[start:end]
+
+	_, err := gcm.Open(p.buffer[start:start], iv, ct, aad)
+	if err != nil {
+		return err
+	}
+
+	p.buffer = p.buffer[:len(p.buffer)-gcm.Overhead()]
+	return nil
+}
+
+// cmScratch is space for the AES-CM IV, tag and ROC of a packet
+type cmScratch struct {
+	ctr [aes.BlockSize]byte
+	tag [sha1.Size]byte
+	roc [4]byte
+}
+
+// https://tools.ietf.org/html/rfc3711#section-4.1.1
+//
+//   IV = (k_s * 2^16) XOR (SSRC * 2^64) XOR (i * 2^16)
+//
+// where i is the 48 bit packet index for SRTP or the 31 bit SRTCP index
+func (c *cmScratch) setIV(salt []byte, ssrc uint32, index uint64) {
+	iv := c.ctr[:]
+	binary.BigEndian.PutUint32(iv[0:], 0)
+	binary.BigEndian.PutUint32(iv[4:], ssrc)
+	binary.BigEndian.PutUint64(iv[8:], index<<16)
+
+	for i := range salt {
+		iv[i] ^= salt[i]
+	}
+}
+
+// xor encrypts or decrypts data in place with block in counter mode starting
+// from the IV set with setIV. The counter mode stream is the only allocation
+// when protecting a packet, the assembly AES-CTR is much faster than
+// encrypting one block at a time.
+func (c *cmScratch) xor(block cipher.Block, data []byte) {
+	cipher.NewCTR(block, c.ctr[:]).XORKeyStream(data, data)
+}
+
+// tagOf computes the HMAC-SHA1 authentication tag over the concatenation of
+// parts, truncated to tagLen bytes
+func (c *cmScratch) tagOf(mac hash.Hash, tagLen int, parts ...[]byte) []byte {
+	mac.Reset()
+	for _, part := range parts {
+		mac.Write(part)
+	}
+	return mac.Sum(c.tag[:0])[:tagLen]
+}
+
+// newCM builds the AES block cipher and keyed HMAC-SHA1 of the AES-CM
+// profiles. Sessions build them once and use EncryptCMBlock and
+// DecryptCMBlock for each packet.
+func newCM(key, authKey []byte) (cipher.Block, hash.Hash, error) {
 	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return block, hmac.New(sha1.New, authKey), nil
+}
+
+// EncryptCM encrypts the payload with AES counter mode and appends a
+// HMAC-SHA1 tag of tagLen bytes over the header, payload and roc. Use
+// EncryptCMBlock to avoid expanding the keys for every packet.
+func (p *RTPPacket) EncryptCM(roc uint32, key, salt, authKey []byte, tagLen int) error {
+	block, mac, err := newCM(key, authKey)
 	if err != nil {
 		return err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	return p.EncryptCMBlock(block, mac, roc, salt, tagLen)
+}
+
+// DecryptCM checks the HMAC-SHA1 tag of tagLen bytes at the end of the
+// packet, removes it and decrypts the payload. Use DecryptCMBlock to avoid
+// expanding the keys for every packet.
+func (p *RTPPacket) DecryptCM(roc uint32, key, salt, authKey []byte, tagLen int) error {
+	block, mac, err := newCM(key, authKey)
 	if err != nil {
 		return err
 	}
 
-	iv := p.gcmIV(roc, salt)
+	return p.DecryptCMBlock(block, mac, roc, salt, tagLen)
+}
 
+// EncryptCMBlock encrypts the payload in place with block and appends a tag
+// from mac, which is reset first. It does not allocate when the packet
+// buffer has room for the tag.
+func (p *RTPPacket) EncryptCMBlock(block cipher.Block, mac hash.Hash, roc uint32, salt []byte, tagLen int) error {
 	start := p.getPayloadOffset()
 	end := len(p.buffer)
 
-	aad := p.buffer[0:start]
-	ct := p.buffer[start:end]
+	if start >= end {
+		return errors.New("rtp: invalid payload size")
+	}
 
-	_, err = gcm.Open(p.buffer[start:start], iv, ct, aad)
-	if err != nil {
-		return err
+	index := uint64(roc)<<16 | uint64(p.GetSeq())
+	p.cm.setIV(salt, p.GetSSRC(), index)
+	p.cm.xor(block, p.buffer[start:end])
+
+	binary.BigEndian.PutUint32(p.cm.roc[:], roc)
+	tag := p.cm.tagOf(mac, tagLen, p.buffer, p.cm.roc[:])
+	p.buffer = append(p.buffer, tag...)
+	return nil
+}
+
+// DecryptCMBlock checks the tag of tagLen bytes at the end of the packet
+// with mac, removes it and decrypts the payload in place with block
+func (p *RTPPacket) DecryptCMBlock(block cipher.Block, mac hash.Hash, roc uint32, salt []byte, tagLen int) error {
+	end := len(p.buffer) - tagLen
+	start := p.getPayloadOffset()
+
+	if start > end {
+		return errors.New("rtp: packet too short for auth tag")
+	}
+
+	binary.BigEndian.PutUint32(p.cm.roc[:], roc)
+	tag := p.cm.tagOf(mac, tagLen, p.buffer[:end], p.cm.roc[:])
+	if !hmac.Equal(tag, p.buffer[end:]) {
+		return errors.New("rtp: authentication failed")
+	}
+	p.buffer = p.buffer[:end]
+
+	index := uint64(roc)<<16 | uint64(p.GetSeq())
+	p.cm.setIV(salt, p.GetSSRC(), index)
+	p.cm.xor(block, p.buffer[start:end])
+	return nil
+}
+
+// checkHeader verifies that the fixed header, CSRC list, and header
+// extention all fit inside the buffer. It does not look at the padding since
+// that is encrypted in SRTP packets.
+func (p *RTPPacket) checkHeader() error {
+	if len(p.buffer) < rtpHeaderSize {
+		return ErrRTPTooShort
+	}
+
+	if p.buffer[0]>>6 != rtpVersion {
+		return ErrRTPVersion
+	}
+
+	offset := p.getHdrExtOffset()
+	if offset > len(p.buffer) {
+		return ErrRTPCSRCOverflow
+	}
+
+	if p.GetExtBit() {
+		if offset+4 > len(p.buffer) {
+			return ErrRTPHdrExtTooLong
+		}
+		if offset+p.GetHdrExtLen() > len(p.buffer) {
+			return ErrRTPHdrExtTooLong
+		}
+	}
+
+	return nil
+}
+
+// checkPadding verifies the pad count in the last byte of the packet is not
+// zero and does not reach back into the header.
+func (p *RTPPacket) checkPadding() error {
+	if !p.GetPad() {
+		return nil
+	}
+
+	start := p.getPayloadOffset()
+	if start >= len(p.buffer) {
+		return ErrRTPBadPadding
+	}
+
+	pad := int(p.buffer[len(p.buffer)-1])
+	if pad == 0 || pad > len(p.buffer)-start {
+		return ErrRTPBadPadding
 	}
 
-	p.buffer = p.buffer[:len(p.buffer)-gcm.Overhead()]
 	return nil
 }
 
+// ParseRTPPacket validates an unencrypted RTP packet received from the
+// network and wraps it in an RTPPacket. The packet refers to buf and does
+// not copy it.
+func ParseRTPPacket(buf []byte) (*RTPPacket, error) {
+	p := new(RTPPacket)
+	p.buffer = buf
+
+	err := p.checkHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.checkPadding()
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
 func NewRTPPacket(payload []byte, payloadType int8, seq uint16, ts uint32, ssrc uint32) *RTPPacket {
 	p := new(RTPPacket)
 	p.buffer = make([]byte, 12 /*RTP Header size*/ +len(payload), MTU)
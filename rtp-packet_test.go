@@ -2,6 +2,8 @@ package rtp
 
 import (
 	// "bytes"
+	"crypto/aes"
+	"crypto/cipher"
 	"encoding/hex"
 	"testing"
 )
@@ -258,3 +260,248 @@ func TestGCM(t *testing.T) {
 
 	compareByteArrays(t, original.buffer, decrypted.buffer)
 }
+
+func TestAEAD(t *testing.T) {
+	plaintext, _ := hex.DecodeString("8040f17b8041f8d35501a0b247616c6c" +
+		"696120657374206f6d6e697320646976" +
+		"69736120696e20706172746573207472" +
+		"6573")
+	key, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	salt, _ := hex.DecodeString("517569642070726f2071756f")
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := RTPPacket{}
+	expected.buffer = append([]byte{}, plaintext...)
+	err = expected.EncryptGCM(0, key, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	p := RTPPacket{}
+	p.buffer = make([]byte, len(plaintext), MTU)
+	copy(p.buffer, plaintext)
+	err = p.EncryptAEAD(gcm, 0, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	compareByteArrays(t, p.buffer, expected.buffer)
+
+	err = p.DecryptAEAD(gcm, 0, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	compareByteArrays(t, p.buffer, plaintext)
+
+	// a packet with room for the tag is processed in place
+	allocs := testing.AllocsPerRun(100, func() {
+		p.EncryptAEAD(gcm, 0, salt)
+		p.DecryptAEAD(gcm, 0, salt)
+	})
+	assertEqual(t, allocs, float64(0))
+
+	p.buffer = p.buffer[:rtpHeaderSize+4]
+	err = p.DecryptAEAD(gcm, 0, salt)
+	if err == nil {
+		t.Fatalf("expected error for packet shorter than tag")
+	}
+}
+
+func benchmarkRTPPacket() *RTPPacket {
+	return NewRTPPacket(make([]byte, 1200), 8 /*pt*/, 22 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+}
+
+func BenchmarkEncryptDecryptGCM(b *testing.B) {
+	key := make([]byte, 16)
+	salt := make([]byte, 12)
+	p := benchmarkRTPPacket()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(p.GetPayload())))
+	for i := 0; i < b.N; i++ {
+		p.EncryptGCM(0, key, salt)
+		p.DecryptGCM(0, key, salt)
+	}
+}
+
+func BenchmarkEncryptDecryptAEAD(b *testing.B) {
+	gcm, _ := newGCM(make([]byte, 16))
+	salt := make([]byte, 12)
+	p := benchmarkRTPPacket()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(p.GetPayload())))
+	for i := 0; i < b.N; i++ {
+		p.EncryptAEAD(gcm, 0, salt)
+		p.DecryptAEAD(gcm, 0, salt)
+	}
+}
+
+func TestParseRTPPacket(t *testing.T) {
+	p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 22 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+	p.SetCSRC([]uint32{66, 67})
+	p.SetHdrExt(77, []byte{99, 11, 12, 14})
+	p.SetPayload([]byte{200, 11, 12, 13})
+	p.SetPadding(48)
+
+	p2, err := ParseRTPPacket(p.buffer)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	assertEqual(t, p2.GetSeq(), uint16(22))
+	assertEqual(t, p2.GetCC(), 2)
+	compareByteArrays(t, p2.GetPayload(), []byte{200, 11, 12, 13})
+
+	good := p.buffer
+
+	bad := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"empty", []byte{}, ErrRTPTooShort},
+		{"short", good[:11], ErrRTPTooShort},
+		{"version", append([]byte{0x40}, good[1:]...), ErrRTPVersion},
+		{"csrc", append([]byte{0x8F}, good[1:20]...), ErrRTPCSRCOverflow},
+		{"ext header", good[:22], ErrRTPHdrExtTooLong},
+		{"ext data", good[:26], ErrRTPHdrExtTooLong},
+		{"zero pad", append(append([]byte{}, good[:47]...), 0), ErrRTPBadPadding},
+		{"big pad", append(append([]byte{}, good[:47]...), 40), ErrRTPBadPadding},
+		{"pad no payload", append([]byte{0xA0}, good[1:12]...), ErrRTPBadPadding},
+	}
+
+	for _, tc := range bad {
+		_, err := ParseRTPPacket(tc.data)
+		if err != tc.err {
+			t.Errorf("%s: expected %v got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+// Test vector from libsrtp's srtp_validate, which uses the master key and
+// salt of https://tools.ietf.org/html/rfc3711#appendix-B.3
+func TestEncryptCM(t *testing.T) {
+	masterKey, _ := hex.DecodeString("e1f97a0d3e018be0d64fa32c06de4139")
+	masterSalt, _ := hex.DecodeString("0ec675ad498afeebb6960b3aabe6")
+	plain, _ := hex.DecodeString("800f1234decafbadcafebabeabababababababababababababababab")
+	golden, _ := hex.DecodeString("800f1234decafbadcafebabe4e55dc4ce79978d88ca4d215949d2402b78d6acc99ea179b8dbb")
+
+	kdf, _ := NewKDF(masterKey, masterSalt)
+	key, salt, _, _, err := kdf.DeriveForStream(SRTP_AES128_CM_HMAC_SHA1_80)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	authKey, _, _ := kdf.DeriveAuthForStream(SRTP_AES128_CM_HMAC_SHA1_80)
+
+	p := RTPPacket{buffer: append([]byte{}, plain...)}
+	err = p.EncryptCM(0, key, salt, authKey, 10)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	compareByteArrays(t, p.buffer, golden)
+
+	err = p.DecryptCM(0, key, salt, authKey, 10)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	compareByteArrays(t, p.buffer, plain)
+
+	// a wrong ROC fails authentication
+	p = RTPPacket{buffer: append([]byte{}, golden...)}
+	err = p.DecryptCM(1, key, salt, authKey, 10)
+	if err == nil {
+		t.Fatalf("expected authentication failure")
+	}
+}
+
+func TestCMBlock(t *testing.T) {
+	masterKey, _ := hex.DecodeString("e1f97a0d3e018be0d64fa32c06de4139")
+	masterSalt, _ := hex.DecodeString("0ec675ad498afeebb6960b3aabe6")
+	plain, _ := hex.DecodeString("800f1234decafbadcafebabeabababababababababababababababab")
+	golden, _ := hex.DecodeString("800f1234decafbadcafebabe4e55dc4ce79978d88ca4d215949d2402b78d6acc99ea179b8dbb")
+
+	kdf, _ := NewKDF(masterKey, masterSalt)
+	key, salt, _, _, err := kdf.DeriveForStream(SRTP_AES128_CM_HMAC_SHA1_80)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	authKey, _, _ := kdf.DeriveAuthForStream(SRTP_AES128_CM_HMAC_SHA1_80)
+	block, mac, err := newCM(key, authKey)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	p := RTPPacket{}
+	p.buffer = make([]byte, len(plain), MTU)
+	copy(p.buffer, plain)
+	err = p.EncryptCMBlock(block, mac, 0, salt, 10)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	compareByteArrays(t, p.buffer, golden)
+
+	err = p.DecryptCMBlock(block, mac, 0, salt, 10)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	compareByteArrays(t, p.buffer, plain)
+
+	// only the counter mode stream of each direction is allocated
+	allocs := testing.AllocsPerRun(100, func() {
+		p.EncryptCMBlock(block, mac, 0, salt, 10)
+		p.DecryptCMBlock(block, mac, 0, salt, 10)
+	})
+	if allocs > 2 {
+		t.Errorf("AES-CM round trip made %v allocations", allocs)
+	}
+}
+
+func TestCMXOR(t *testing.T) {
+	block, _ := aes.NewCipher(make([]byte, 16))
+	salt := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+
+	// counter mode over several blocks with a partial last block, and with
+	// a carry out of the packet index bytes of the counter
+	for _, index := range []uint64{1, 0xffffffffffff} {
+		for _, n := range []int{1, 16, 17, 100} {
+			var c cmScratch
+			c.setIV(salt, 44, index)
+			expected := make([]byte, n)
+			cipher.NewCTR(block, c.ctr[:]).XORKeyStream(expected, expected)
+
+			data := make([]byte, n)
+			c.xor(block, data)
+			compareByteArrays(t, data, expected)
+		}
+	}
+}
+
+func BenchmarkEncryptDecryptCM(b *testing.B) {
+	key := make([]byte, 16)
+	salt := make([]byte, 14)
+	authKey := make([]byte, 20)
+	p := benchmarkRTPPacket()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(p.GetPayload())))
+	for i := 0; i < b.N; i++ {
+		p.EncryptCM(0, key, salt, authKey, 10)
+		p.DecryptCM(0, key, salt, authKey, 10)
+	}
+}
+
+func BenchmarkEncryptDecryptCMBlock(b *testing.B) {
+	block, mac, _ := newCM(make([]byte, 16), make([]byte, 20))
+	salt := make([]byte, 14)
+	p := benchmarkRTPPacket()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(p.GetPayload())))
+	for i := 0; i < b.N; i++ {
+		p.EncryptCMBlock(block, mac, 0, salt, 10)
+		p.DecryptCMBlock(block, mac, 0, salt, 10)
+	}
+}
@@ -5,14 +5,21 @@ EKT defined in https://tools.ietf.org/html/draft-ietf-perc-srtp-ekt-diet-07
 
 SRTP Profiles are at https://www.iana.org/assignments/srtp-protection/srtp-protection.xhtml
 
-Currently only support DOUBLE_AEAD_AES_128_GCM_AEAD_AES_128_GCM  in half mode with EKT
+Currently only support DOUBLE_AEAD_AES_128_GCM_AEAD_AES_128_GCM  in half mode with EKT,
+the AEAD GCM profiles, and the AES-CM with HMAC-SHA1 profiles
 */
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"time"
 )
 
 type CipherID uint16
@@ -22,36 +29,104 @@ type Ssrc uint32
 const (
 	// From https://www.iana.org/assignments/srtp-protection/srtp-protection.xhtml
 	NONE                                     CipherID = 0x0000
+	SRTP_AES128_CM_HMAC_SHA1_80              CipherID = 0x0001
+	SRTP_AES128_CM_HMAC_SHA1_32              CipherID = 0x0002
 	SRTP_AEAD_AES_128_GCM                    CipherID = 0x0007
 	SRTP_AEAD_AES_256_GCM                    CipherID = 0x0008
 	DOUBLE_AEAD_AES_128_GCM_AEAD_AES_128_GCM CipherID = 0x0009
 	DOUBLE_AEAD_AES_256_GCM_AEAD_AES_256_GCM CipherID = 0x000a
 )
 
+const (
+	ExtURIClientVolume = "urn:ietf:params:rtp-hdrext:ssrc-audio-level"
+	ExtURIMixerVolume  = "urn:ietf:params:rtp-hdrext:csrc-audio-level"
+	ExtURIAbsSendTime  = "http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time"
+	ExtURITransportSeq = "http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01"
+	ExtURIMID          = "urn:ietf:params:rtp-hdrext:sdes:mid"
+	ExtURIRID          = "urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id"
+	ExtURIRepairedRID  = "urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id"
+)
+
 type RTPSession struct {
 	extNameMap map[string]int
+	extTypeMap map[reflect.Type]string
 	key        []byte
 	salt       []byte
-	seq        uint16
-	roc        uint32
+	seq        uint16 // initial sequence number of the next sending SSRC
 	rtcpKey    []byte
 	rtcpSalt   []byte
 
+	// only used by the AEAD profiles, built once from key and rtcpKey
+	rtpAEAD  cipher.AEAD
+	rtcpAEAD cipher.AEAD
+
+	// only used by the AES-CM profiles, built once from key and rtcpKey
+	rtpBlock  cipher.Block
+	rtcpBlock cipher.Block
+
+	// only used by the AES-CM profiles
+	authKey     []byte
+	rtcpAuthKey []byte
+	tagLen      int
+	rtpMACs     *macPool
+	rtcpMACs    *macPool
+
 	cipher CipherID
 	useEKT bool
 	rewriteSeq bool
+
+	rtcpReducedSize bool
+
+	mu         sync.Mutex // guards the statistics, source table, crypto contexts and transportSeq
+	receivers  map[uint32]*receiverSource
+	senders    map[uint32]*senderSource
+	clockRates map[int8]uint32
+
+	sentReports []sentReport
+	remoteSRs   map[uint32]remoteSR
+	rtts        map[uint32]*RTTStats
+
+	sources     map[uint32]*sourceEntry
+	localSSRCs  map[uint32]bool
+	ssrcRemap   map[uint32]uint32
+	conflicts   map[string]time.Time
+	ssrcHandler func(SSRCEvent)
+
+	replaySize uint64
+	txContexts map[uint32]*cryptoContext
+	rxContexts map[uint32]*cryptoContext
+
+	stampAbsSendTime  bool
+	stampTransportSeq bool
+	transportSeq      uint16
+
+	now func() time.Time
 }
 
 func (s *RTPSession) Decode(packetData []byte) (*RTPPacket, error) {
+	return s.DecodeFrom(packetData, nil)
+}
+
+// DecodeFrom decodes a packet received from the transport address from and
+// checks its SSRC against the source table for collisions and loops
+func (s *RTPSession) DecodeFrom(packetData []byte, from net.Addr) (*RTPPacket, error) {
 
 	p := new(RTPPacket)
+	p.buffer = packetData
 
 	if s.useEKT {
+		if len(packetData) == 0 {
+			return nil, ErrRTPTooShort
+		}
 		ektCmd := packetData[len(packetData)-1]
 		ektLen := 0
 		if ektCmd == 0 {
 			ektLen = 1
 		} else if ektCmd == 0x02 {
+			if len(packetData) < 2+2+1 {
+				// no room for the SPI, len and type
+				return nil, errors.New("rtp: invalid EKT field - too short")
+			}
 			ektLen = int(binary.BigEndian.Uint16(packetData[len(packetData)-3:]))
 			ektLen += 2 + 2 + 1 // SPI + len + type
 		} else {
@@ -67,15 +142,53 @@ func (s *RTPSession) Decode(packetData []byte) (*RTPPacket, error) {
 		p.ekt = packetData[len(packetData)-ektLen : len(packetData)]
 	}
 
+	err := p.checkHeader()
+	if err != nil {
+		return nil, err
+	}
+
 	if s.cipher != NONE {
-		err := p.DecryptGCM(s.roc, s.key, s.salt)
+		// the 48 bit packet index is estimated from the ROC of the source,
+		// checked before and committed after authentication
+		index, err := s.checkRTPIndex(p.GetSSRC(), p.GetSeq())
 		if err != nil {
 			return nil, err
 		}
+		roc := uint32(index >> 16)
 
-		// remove the OHB if double RTP ( but not RTCP )
-		ohbLen := p.GetOHBLen()
-		p.buffer = p.buffer[0 : len(p.buffer)-ohbLen]
+		if s.isCM() {
+			mac := s.rtpMACs.get()
+			err = p.DecryptCMBlock(s.rtpBlock, mac, roc, s.salt, s.tagLen)
+			s.rtpMACs.put(mac)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			err = p.DecryptAEAD(s.rtpAEAD, roc, s.salt)
+			if err != nil {
+				return nil, err
+			}
+
+			// remove the OHB if double RTP ( but not RTCP )
+			ohbLen := p.GetOHBLen()
+			p.buffer = p.buffer[0 : len(p.buffer)-ohbLen]
+		}
+		err = s.commitRTPIndex(p.GetSSRC(), index)
+		if err != nil {
+			return nil, err
+		}
+
+		err = p.checkPadding()
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.checkSource(p.GetSSRC(), from, false)
+		if err != nil {
+			return nil, err
+		}
+
+		s.updateReceiverStats(p)
 	} else {
 		return nil, errors.New("rtp: cipher algorithm not supported")
 	}
@@ -84,16 +197,56 @@ func (s *RTPSession) Decode(packetData []byte) (*RTPPacket, error) {
 }
 
 func (s *RTPSession) DecodeRTCP(packetData []byte) (*RTCPCompoundPacket, error) {
-	p, err := NewSRTCPPacket(packetData)
+	return s.DecodeRTCPFrom(packetData, nil)
+}
+
+// DecodeRTCPFrom decodes a compound packet received from the transport
+// address from and checks the sender SSRCs against the source table
+func (s *RTPSession) DecodeRTCPFrom(packetData []byte, from net.Addr) (*RTCPCompoundPacket, error) {
+	var p *RTCPCompoundPacket
+	var err error
+	if s.isCM() {
+		p, err = NewSRTCPPacketCM(packetData)
+	} else {
+		p, err = NewSRTCPPacket(packetData)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	if s.cipher != NONE {
-		err = p.DecryptGCM(s.rtcpKey, s.rtcpSalt)
+		ssrc := p.header.GetSenderSSRC()
+		index := p.GetSRTCPIndex()
+		err := s.checkRTCPIndex(ssrc, index)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.isCM() {
+			mac := s.rtcpMACs.get()
+			err = p.DecryptCMBlock(s.rtcpBlock, mac, s.rtcpSalt)
+			s.rtcpMACs.put(mac)
+		} else {
+			err = p.DecryptAEAD(s.rtcpAEAD, s.rtcpSalt)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = s.commitRTCPIndex(ssrc, index)
+		if err != nil {
+			return nil, err
+		}
+
+		p.SetReducedSize(s.rtcpReducedSize)
+		msgs, err := p.GetMessages()
+		if err != nil {
+			return nil, err
+		}
+		err = s.checkRTCPSources(msgs, from)
 		if err != nil {
 			return nil, err
 		}
+		s.processRTCP(msgs, s.now())
 
 		return p, nil
 	}
@@ -101,43 +254,82 @@ func (s *RTPSession) DecodeRTCP(packetData []byte) (*RTCPCompoundPacket, error)
 	return nil, errors.New("rtcp: cipher algorithm not supported")
 }
 
+// stampExts sets the header extentions that Encode adds to every packet
+func (s *RTPSession) stampExts(p *RTPPacket) error {
+	if s.stampAbsSendTime && s.extNameMap[ExtURIAbsSendTime] != 0 {
+		err := p.SetExtAbsSendTime(s, s.now())
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.stampTransportSeq && s.extNameMap[ExtURITransportSeq] != 0 {
+		s.mu.Lock()
+		seq := s.transportSeq
+		s.transportSeq++
+		s.mu.Unlock()
+
+		err := p.SetExtTransportSeq(s, seq)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (s *RTPSession) Encode(p *RTPPacket) ([]byte, error) {
+	// a local SSRC that collided is sent with its replacement
+	err := p.SetSSRC(s.mapLocalSSRC(p.GetSSRC()))
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.stampExts(p)
+	if err != nil {
+		return nil, err
+	}
+
+	// sender statistics count the plain payload octets
+	statsPt := p.GetPT()
+	statsLen := len(p.GetPayload())
+
 	if s.cipher != NONE {
 		// Form the OHB with old seq
 		origPt := p.GetPT()
 		origSeq := p.GetSeq()
 		origMarker := p.GetMarker()
 
-		// Set the seq number
-		if (  s.rewriteSeq ) {
-			err := p.SetSeq(s.seq)
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		err := p.SetOHB(origPt, origSeq, origMarker)
+		// Set the seq number and find the ROC of this SSRC
+		roc, err := s.nextTxROC(p)
 		if err != nil {
 			return nil, err
 		}
 
-		// encrypt
-		err = p.EncryptGCM(s.roc, s.key, s.salt)
-		if err != nil {
-			return nil, err
+		if s.isCM() {
+			// the AES-CM profiles have no OHB
+			mac := s.rtpMACs.get()
+			err = p.EncryptCMBlock(s.rtpBlock, mac, roc, s.salt, s.tagLen)
+			s.rtpMACs.put(mac)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			err = p.SetOHB(origPt, origSeq, origMarker)
+			if err != nil {
+				return nil, err
+			}
+
+			// encrypt
+			err = p.EncryptAEAD(s.rtpAEAD, roc, s.salt)
+			if err != nil {
+				return nil, err
+			}
 		}
 	} else {
 		return nil, errors.New("rtp: cipher algorithm not supported")
 	}
 
-	if (  s.rewriteSeq ) {
-		// increment seq
-		s.seq++
-		if s.seq == 0 {
-			s.roc++
-		}
-	}
-
 	if s.useEKT {
 		// add back EKT
 		rtpLen := len(p.buffer)
@@ -150,12 +342,26 @@ func (s *RTPSession) Encode(p *RTPPacket) ([]byte, error) {
 		copy(p.buffer[rtpLen:rtpLen+ektLen], p.ekt)
 	}
 
+	s.updateSenderStats(p.GetSSRC(), statsPt, p.GetTimestamp(), statsLen)
+
 	return p.buffer, nil
 }
 
 func (s* RTPSession) EncodeRTCP(p* RTCPCompoundPacket) ([]byte, error) {
 	if s.cipher != NONE {
-		err := p.EncryptGCM(s.rtcpKey, s.rtcpSalt)
+		p.SetReducedSize(s.rtcpReducedSize)
+		_, err := p.GetPackets()
+		if err != nil {
+			return nil, err
+		}
+
+		if s.isCM() {
+			mac := s.rtcpMACs.get()
+			err = p.EncryptCMBlock(s.rtcpBlock, mac, s.rtcpSalt)
+			s.rtcpMACs.put(mac)
+		} else {
+			err = p.EncryptAEAD(s.rtcpAEAD, s.rtcpSalt)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -166,8 +372,63 @@ func (s* RTPSession) EncodeRTCP(p* RTCPCompoundPacket) ([]byte, error) {
 	}
 }
 
-func (s *RTPSession) NewRtcpRR() (*RTPPacket, error) {
-	return nil, nil
+// MarshalRTCP forms a compound RTCP packet from msgs. When reduced-size
+// RTCP is enabled, msgs that do not start with a SR or RR are sent as a
+// reduced-size packet instead.
+func (s *RTPSession) MarshalRTCP(msgs []RTCPMessage) ([]byte, error) {
+	if s.rtcpReducedSize && len(msgs) > 0 {
+		pt := msgs[0].GetPT()
+		if pt != RTCPTypeSR && pt != RTCPTypeRR {
+			return MarshalRTCPReducedSize(msgs)
+		}
+	}
+
+	return MarshalRTCPCompound(msgs)
+}
+
+// NewRtcpRR forms a Receiver Report from ssrc with a report block for each
+// remote source and starts a new reporting interval
+func (s *RTPSession) NewRtcpRR(ssrc uint32) (*ReceiverReport, error) {
+	ssrc = s.mapLocalSSRC(ssrc)
+	rr := &ReceiverReport{
+		SSRC:    ssrc,
+		Reports: s.reportBlocks(s.now()),
+	}
+
+	return rr, nil
+}
+
+// NewRtcpSR forms a Sender Report for the local ssrc, mapping the current
+// wall clock time to the RTP timestamp of the last packet sent, with a report
+// block for each remote source. It starts a new reporting interval.
+func (s *RTPSession) NewRtcpSR(ssrc uint32) (*SenderReport, error) {
+	ssrc = s.mapLocalSSRC(ssrc)
+	now := s.now()
+
+	s.mu.Lock()
+	src, ok := s.senders[ssrc]
+	if !ok {
+		s.mu.Unlock()
+		return nil, errors.New("rtp: no packets sent from ssrc")
+	}
+	rate, ok := s.clockRates[src.pt]
+	if !ok {
+		s.mu.Unlock()
+		return nil, errors.New("rtp: clock rate not set for payload type")
+	}
+	sr := &SenderReport{
+		SSRC:        ssrc,
+		NTPTime:     toNTP(now),
+		RTPTime:     src.rtpTime(now, rate),
+		PacketCount: src.packetCount,
+		OctetCount:  src.octetCount,
+	}
+	s.recordSentReport(ssrc, sr.NTPTime, now)
+	s.mu.Unlock()
+
+	sr.Reports = s.reportBlocks(now)
+
+	return sr, nil
 }
 
 func (s *RTPSession) SetSRTP(cipher CipherID, useEKT bool, masterKey, masterSalt []byte) error {
@@ -181,7 +442,39 @@ func (s *RTPSession) SetSRTP(cipher CipherID, useEKT bool, masterKey, masterSalt
 		return err
 	}
 
-	fmt.Printf("SRTP encryption key: %x\n", rtpKey)
+	switch cipher {
+	case SRTP_AES128_CM_HMAC_SHA1_80, SRTP_AES128_CM_HMAC_SHA1_32:
+		s.authKey, s.rtcpAuthKey, err = kdf.DeriveAuthForStream(cipher)
+		if err != nil {
+			return err
+		}
+		s.tagLen = 10
+		if cipher == SRTP_AES128_CM_HMAC_SHA1_32 {
+			s.tagLen = 4
+		}
+
+		// expand the keys once rather than for every packet
+		s.rtpBlock, err = aes.NewCipher(rtpKey)
+		if err != nil {
+			return err
+		}
+		s.rtcpBlock, err = aes.NewCipher(rtcpKey)
+		if err != nil {
+			return err
+		}
+		s.rtpMACs = newMACPool(s.authKey)
+		s.rtcpMACs = newMACPool(s.rtcpAuthKey)
+	default:
+		// expand the keys once rather than for every packet
+		s.rtpAEAD, err = newGCM(rtpKey)
+		if err != nil {
+			return err
+		}
+		s.rtcpAEAD, err = newGCM(rtcpKey)
+		if err != nil {
+			return err
+		}
+	}
 
 	s.key = rtpKey
 	s.salt = rtpSalt
@@ -189,13 +482,35 @@ func (s *RTPSession) SetSRTP(cipher CipherID, useEKT bool, masterKey, masterSalt
 	s.rtcpSalt = rtcpSalt
 	s.cipher = cipher
 	s.useEKT = useEKT
+
 	return nil
 }
 
+// isCM is true for the AES-CM with HMAC-SHA1 profiles
+func (s *RTPSession) isCM() bool {
+	return s.cipher == SRTP_AES128_CM_HMAC_SHA1_80 || s.cipher == SRTP_AES128_CM_HMAC_SHA1_32
+}
+
+// SetExtStamping makes Encode set the abs-send-time and transport wide
+// sequence number extentions on every packet. Each is only added once its
+// URI has been mapped with SetExtMap.
+func (s *RTPSession) SetExtStamping(absSendTime, transportSeq bool) {
+	s.stampAbsSendTime = absSendTime
+	s.stampTransportSeq = transportSeq
+}
+
+// SetReducedSizeRTCP allows EncodeRTCP and DecodeRTCP to handle
+// reduced-size RTCP packets as negotiated with a=rtcp-rsize in
+// https://tools.ietf.org/html/rfc5506
+func (s *RTPSession) SetReducedSizeRTCP(enable bool) {
+	s.rtcpReducedSize = enable
+}
+
 func (s *RTPSession) SetExtMap(num int, name string) error {
 
-	if num > 14 {
-		return errors.New("rtp SetExtMap 2 byte headers are not implemented")
+	// IDs above 14 need the two byte header form from extmap-allow-mixed
+	if (num < 1) || (num > 255) {
+		return errors.New("rtp: SetExtMap extention number out of range")
 	}
 
 	s.extNameMap[name] = num
@@ -203,9 +518,74 @@ func (s *RTPSession) SetExtMap(num int, name string) error {
 	return nil
 }
 
+// RegisterExt binds the header extention URI to the type of codec so that
+// values of that type can be used with SetExt and GetExt. codec may be a
+// value or a pointer, and a pointer to its type must implement
+// ExtUnmarshaler.
+func (s *RTPSession) RegisterExt(uri string, codec ExtMarshaler) error {
+	t := extType(codec)
+	if t == nil {
+		return errors.New("rtp: RegisterExt needs a typed codec")
+	}
+	if !reflect.PtrTo(t).Implements(extUnmarshalerType) {
+		return errors.New("rtp: RegisterExt codec can not be unmarshaled")
+	}
+
+	if other, ok := s.extTypeMap[t]; ok && other != uri {
+		return fmt.Errorf("rtp: extention type %s already registered for %s", t, other)
+	}
+
+	s.extTypeMap[t] = uri
+
+	return nil
+}
+
+// getExtNum returns the extention number mapped to the URI registered for
+// the type of value, or zero if the URI has no extmap
+func (s *RTPSession) getExtNum(value interface{}) (int, error) {
+	uri, ok := s.extTypeMap[extType(value)]
+	if !ok {
+		return 0, errors.New("rtp: extention type not registered")
+	}
+
+	return s.extNameMap[uri], nil
+}
+
 func NewRTPSession( rewriteSeq bool ) *RTPSession {
 	s := new(RTPSession)
 	s.extNameMap = make(map[string]int)
+	s.extTypeMap = make(map[reflect.Type]string)
+
+	s.now = time.Now
+	s.receivers = make(map[uint32]*receiverSource)
+	s.senders = make(map[uint32]*senderSource)
+	s.remoteSRs = make(map[uint32]remoteSR)
+	s.rtts = make(map[uint32]*RTTStats)
+	s.clockRates = make(map[int8]uint32)
+	s.sources = make(map[uint32]*sourceEntry)
+	s.localSSRCs = make(map[uint32]bool)
+	s.ssrcRemap = make(map[uint32]uint32)
+	s.conflicts = make(map[string]time.Time)
+	s.replaySize = defaultReplayWindow
+	s.txContexts = make(map[uint32]*cryptoContext)
+	s.rxContexts = make(map[uint32]*cryptoContext)
+
+	exts := map[string]ExtMarshaler{
+		ExtURIClientVolume: ExtClientVolume{},
+		ExtURIMixerVolume:  ExtMixerVolume(nil),
+		ExtURIAbsSendTime:  ExtAbsSendTime(0),
+		ExtURITransportSeq: ExtTransportSeq(0),
+		ExtURIMID:          ExtMID(""),
+		ExtURIRID:          ExtRID(""),
+		ExtURIRepairedRID:  ExtRepairedRID(""),
+	}
+	for uri, codec := range exts {
+		err := s.RegisterExt(uri, codec)
+		if err != nil {
+			fmt.Printf("rtp:NewRTPSession got %s\n", err.Error())
+			return nil
+		}
+	}
 
 	randBytes := make([]byte, 2)
 	_, err := rand.Read(randBytes)
@@ -214,7 +594,6 @@ func NewRTPSession( rewriteSeq bool ) *RTPSession {
 		return nil
 	}
 	s.seq = binary.BigEndian.Uint16(randBytes) & 0x7FFF
-	s.roc = 0
 
 	s.rewriteSeq = rewriteSeq;
 
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestEncode(t *testing.T) {
@@ -67,3 +69,263 @@ func TestDecode(t *testing.T) {
 		t.Errorf("payload data  is wrong")
 	}
 }
+
+func TestDecodeShort(t *testing.T) {
+	data, _ := hex.DecodeString("8008002a000000210000002c520253e5c904fd04ac02aea781b9531c29e45a5fae00")
+
+	s := NewRTPSession(true)
+	key := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	salt := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+	err := s.SetSRTP(SRTP_AEAD_AES_128_GCM, true, key, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	for i := 0; i < len(data); i++ {
+		short := make([]byte, i)
+		copy(short, data)
+		_, err = s.Decode(short)
+		if err == nil {
+			t.Errorf("Decode of %d byte packet did not fail", i)
+		}
+	}
+
+	// EKT command byte with no room for the rest of the EKT field
+	for _, short := range [][]byte{{0x02}, {0x80, 0x02}, {0x80, 0x00, 0x00, 0x02}} {
+		_, err = s.Decode(short)
+		if err == nil {
+			t.Errorf("Decode of %x did not fail", short)
+		}
+	}
+
+	// extention bit set with no room for the extention header
+	short := make([]byte, 14)
+	copy(short, data[:13])
+	short[0] |= 0x10
+	_, err = s.Decode(short)
+	if err != ErrRTPHdrExtTooLong {
+		t.Errorf("Expected %v got %v", ErrRTPHdrExtTooLong, err)
+	}
+}
+
+func TestEncodeStampExts(t *testing.T) {
+	key := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	salt := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+	now := time.Unix(1600000000, 0)
+
+	tx := NewRTPSession(true)
+	err := tx.SetSRTP(SRTP_AEAD_AES_128_GCM, true, key, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	tx.SetExtMap(2, ExtURIAbsSendTime)
+	tx.SetExtMap(3, ExtURITransportSeq)
+	tx.SetExtStamping(true, true)
+	tx.now = func() time.Time { return now }
+
+	rx := NewRTPSession(true)
+	err = rx.SetSRTP(SRTP_AEAD_AES_128_GCM, true, key, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	rx.SetExtMap(2, ExtURIAbsSendTime)
+	rx.SetExtMap(3, ExtURITransportSeq)
+
+	for i := 0; i < 3; i++ {
+		p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 0 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+		data, err := tx.Encode(p)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		p2, err := rx.Decode(data)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		seq, ok := p2.GetExtTransportSeq(rx)
+		assertEqual(t, ok, true)
+		assertEqual(t, seq, uint16(i))
+
+		ast, ok := p2.GetExtAbsSendTime(rx)
+		assertEqual(t, ok, true)
+		assertEqual(t, ast, AbsSendTime(now))
+
+		compareByteArrays(t, p2.GetPayload(), []byte{1, 2, 3, 4})
+	}
+}
+
+func TestEncodeStampExtsConcurrent(t *testing.T) {
+	for _, cipher := range []CipherID{SRTP_AEAD_AES_128_GCM, SRTP_AES128_CM_HMAC_SHA1_80} {
+		testEncodeStampExtsConcurrent(t, cipher)
+	}
+}
+
+func testEncodeStampExtsConcurrent(t *testing.T, cipher CipherID) {
+	key := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	salt := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+
+	tx := NewRTPSession(true)
+	err := tx.SetSRTP(cipher, false, key, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	tx.SetExtMap(3, ExtURITransportSeq)
+	tx.SetExtStamping(false, true)
+
+	const senders = 8
+	const packets = 100
+	seqs := make(chan uint16, senders*packets)
+
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(ssrc uint32) {
+			defer wg.Done()
+			for j := 0; j < packets; j++ {
+				p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 0 /*seq*/, 33 /*ts*/, ssrc)
+				_, err := tx.Encode(p)
+				if err != nil {
+					t.Errorf(err.Error())
+					return
+				}
+				seq, _ := p.GetExtTransportSeq(tx)
+				seqs <- seq
+			}
+		}(uint32(100 + i))
+	}
+	wg.Wait()
+	close(seqs)
+
+	// every packet gets its own transport wide sequence number
+	seen := make(map[uint16]bool)
+	for seq := range seqs {
+		if seen[seq] {
+			t.Fatalf("transport sequence number %d used twice", seq)
+		}
+		seen[seq] = true
+	}
+	assertEqual(t, len(seen), senders*packets)
+}
+
+func TestRTCPReducedSizeSession(t *testing.T) {
+	key := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	salt := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+
+	tx := NewRTPSession(true)
+	err := tx.SetSRTP(SRTP_AEAD_AES_128_GCM, false, key, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	rx := NewRTPSession(true)
+	err = rx.SetSRTP(SRTP_AEAD_AES_128_GCM, false, key, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	pli := &PictureLossIndication{SenderSSRC: 44, MediaSSRC: 55}
+	_, err = tx.MarshalRTCP([]RTCPMessage{pli})
+	assertEqual(t, err, ErrRTCPFirstPacket)
+
+	// a reduced-size packet is rejected unless the option is set
+	plain, _ := MarshalRTCPReducedSize([]RTCPMessage{pli})
+	p, _ := NewRTCPCompoundPacket(append([]byte{}, plain...), 1)
+	_, err = tx.EncodeRTCP(p)
+	assertEqual(t, err, ErrRTCPFirstPacket)
+
+	tx.SetReducedSizeRTCP(true)
+	plain, err = tx.MarshalRTCP([]RTCPMessage{pli})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	p, _ = NewRTCPCompoundPacket(append([]byte{}, plain...), 1)
+	data, err := tx.EncodeRTCP(p)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = rx.DecodeRTCP(data)
+	assertEqual(t, err, ErrRTCPFirstPacket)
+
+	rx.SetReducedSizeRTCP(true)
+	p, _ = NewRTCPCompoundPacket(plain, 2)
+	data, err = tx.EncodeRTCP(p)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	sp, err := rx.DecodeRTCP(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	msgs, err := sp.GetMessages()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(msgs), 1)
+	assertEqual(t, *msgs[0].(*PictureLossIndication), *pli)
+}
+
+func TestSessionCM(t *testing.T) {
+	key := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	salt := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+
+	for _, tc := range []struct {
+		cipher CipherID
+		tagLen int
+	}{
+		{SRTP_AES128_CM_HMAC_SHA1_80, 10},
+		{SRTP_AES128_CM_HMAC_SHA1_32, 4},
+	} {
+		tx := NewRTPSession(true)
+		err := tx.SetSRTP(tc.cipher, false, key, salt)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		rx := NewRTPSession(true)
+		err = rx.SetSRTP(tc.cipher, false, key, salt)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 0 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+		data, err := tx.Encode(p)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		// no OHB, just the tag
+		assertEqual(t, len(data), 12+4+tc.tagLen)
+
+		p2, err := rx.Decode(data)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		compareByteArrays(t, p2.GetPayload(), []byte{1, 2, 3, 4})
+
+		rr := &ReceiverReport{SSRC: 44}
+		sdes := &SourceDescription{Chunks: []SDESChunk{
+			{SSRC: 44, Items: []SDESItem{{Type: SDESCNAME, Text: "test"}}},
+		}}
+		plain, err := MarshalRTCPCompound([]RTCPMessage{rr, sdes})
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		cp, _ := NewRTCPCompoundPacket(append([]byte{}, plain...), 3)
+		data, err = tx.EncodeRTCP(cp)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		// SRTCP always has a 80 bit tag
+		assertEqual(t, len(data), len(plain)+4+10)
+
+		sp, err := rx.DecodeRTCP(data)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		assertEqual(t, sp.GetSRTCPIndex(), uint32(3))
+		msgs, err := sp.GetMessages()
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		assertEqual(t, msgs[0].(*ReceiverReport).SSRC, uint32(44))
+	}
+}
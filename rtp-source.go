@@ -0,0 +1,298 @@
+package rtp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+)
+
+// conflictTimeout is how long a conflicting transport address is remembered,
+// ten times the minimum RTCP interval as suggested in
+// https://tools.ietf.org/html/rfc3550#section-8.2
+const conflictTimeout = 10 * rtcpMinTime
+
+// sourceMoveTimeout is how long the address of a remote source must be
+// silent before the source may move to a new address, the five RTCP intervals
+// after which https://tools.ietf.org/html/rfc3550#section-6.3.5 times out a
+// member
+const sourceMoveTimeout = 5 * rtcpMinTime
+
+// Errors returned by DecodeFrom and DecodeRTCPFrom when the SSRC of a
+// packet conflicts with the source table. The packet should be dropped.
+var (
+	ErrSSRCCollision = errors.New("rtp: SSRC collision with local source")
+	ErrSSRCLoop      = errors.New("rtp: own traffic looped back")
+	ErrSSRCConflict  = errors.New("rtp: SSRC seen from a different transport address")
+)
+
+type SSRCEventType int
+
+const (
+	// SSRCEventCollision is a remote source using a local SSRC. The local
+	// source now uses NewSSRC and Bye should be sent for the old SSRC.
+	SSRCEventCollision SSRCEventType = iota
+	// SSRCEventLoop is our own traffic received from an address that
+	// already caused a collision
+	SSRCEventLoop
+	// SSRCEventConflict is a remote SSRC received from a different
+	// transport address than before, a collision between third parties or a
+	// loop
+	SSRCEventConflict
+)
+
+// SSRCEvent reports a collision or loop found by the source table
+type SSRCEvent struct {
+	Type    SSRCEventType
+	SSRC    uint32
+	NewSSRC uint32   // set for SSRCEventCollision
+	Bye     *Goodbye // set for SSRCEventCollision
+	Addr    net.Addr // the address the packet came from
+	RTCP    bool     // the packet was RTCP
+}
+
+// sourceAddr is a transport address a source sends from and when it was
+// last seen there
+type sourceAddr struct {
+	addr net.Addr
+	seen time.Time
+}
+
+// sourceEntry is the transport addresses a source has been seen from
+type sourceEntry struct {
+	rtp  sourceAddr
+	rtcp sourceAddr
+}
+
+func (e *sourceEntry) addr(rtcp bool) *sourceAddr {
+	if rtcp {
+		return &e.rtcp
+	}
+	return &e.rtp
+}
+
+func sameAddr(a, b net.Addr) bool {
+	return a.Network() == b.Network() && a.String() == b.String()
+}
+
+// SetSSRCEventHandler sets a function that is called when a SSRC collision
+// or loop is detected
+func (s *RTPSession) SetSSRCEventHandler(handler func(SSRCEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ssrcHandler = handler
+}
+
+// ResetSourceAddr forgets the transport addresses of the remote ssrc so its
+// next packets may come from a new address, for example after an ICE
+// restart. Without it a source only moves once its old address has been
+// silent for a while.
+func (s *RTPSession) ResetSourceAddr(ssrc uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.sources[ssrc]; ok {
+		*e = sourceEntry{}
+	}
+}
+
+// addLocalSSRC must be called with s.mu held
+func (s *RTPSession) addLocalSSRC(ssrc uint32) {
+	s.localSSRCs[ssrc] = true
+}
+
+// mapLocalSSRC returns the SSRC that packets from the local ssrc are sent
+// with, which differs when ssrc has collided
+func (s *RTPSession) mapLocalSSRC(ssrc uint32) uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if newSSRC, ok := s.ssrcRemap[ssrc]; ok {
+		ssrc = newSSRC
+	}
+	s.addLocalSSRC(ssrc)
+	return ssrc
+}
+
+// newLocalSSRC picks a random SSRC not in use by any known source. It must
+// be called with s.mu held.
+func (s *RTPSession) newLocalSSRC() (uint32, error) {
+	buf := make([]byte, 4)
+	for {
+		_, err := rand.Read(buf)
+		if err != nil {
+			return 0, err
+		}
+		ssrc := binary.BigEndian.Uint32(buf)
+		if _, ok := s.sources[ssrc]; ok || s.localSSRCs[ssrc] {
+			continue
+		}
+		return ssrc, nil
+	}
+}
+
+// checkSource follows the source table algorithm of
+// https://tools.ietf.org/html/rfc3550#section-8.2 for a packet with ssrc
+// received from addr. It returns an error when the packet should be dropped.
+// With no addr only collisions with a local SSRC are found.
+func (s *RTPSession) checkSource(ssrc uint32, addr net.Addr, rtcp bool) error {
+	now := s.now()
+
+	s.mu.Lock()
+	ev, err := s.updateSource(ssrc, addr, rtcp, now)
+	handler := s.ssrcHandler
+	s.mu.Unlock()
+
+	if ev != nil && handler != nil {
+		handler(*ev)
+	}
+	return err
+}
+
+// updateSource must be called with s.mu held
+func (s *RTPSession) updateSource(ssrc uint32, addr net.Addr, rtcp bool, now time.Time) (*SSRCEvent, error) {
+	for key, seen := range s.conflicts {
+		if now.Sub(seen) > conflictTimeout {
+			delete(s.conflicts, key)
+		}
+	}
+
+	if !s.localSSRCs[ssrc] {
+		if addr == nil {
+			return nil, nil
+		}
+
+		e, ok := s.sources[ssrc]
+		if !ok {
+			e = new(sourceEntry)
+			s.sources[ssrc] = e
+		}
+		saved := e.addr(rtcp)
+		if saved.addr == nil || sameAddr(saved.addr, addr) || now.Sub(saved.seen) > sourceMoveTimeout {
+			// first data or control packet from this source, or the source
+			// moved away from an address that went silent
+			*saved = sourceAddr{addr: addr, seen: now}
+			return nil, nil
+		}
+
+		ev := &SSRCEvent{Type: SSRCEventConflict, SSRC: ssrc, Addr: addr, RTCP: rtcp}
+		return ev, ErrSSRCConflict
+	}
+
+	// a packet with our own SSRC. Loops can only be told apart from
+	// collisions by the address they come from.
+	var key string
+	if addr != nil {
+		key = addr.Network() + "/" + addr.String()
+		if _, ok := s.conflicts[key]; ok {
+			s.conflicts[key] = now
+			ev := &SSRCEvent{Type: SSRCEventLoop, SSRC: ssrc, Addr: addr, RTCP: rtcp}
+			return ev, ErrSSRCLoop
+		}
+	}
+
+	newSSRC, err := s.newLocalSSRC()
+	if err != nil {
+		return nil, err
+	}
+	if addr != nil {
+		s.conflicts[key] = now
+	}
+
+	// packets sent from ssrc, or from an SSRC that was remapped to it, now
+	// go out with the new SSRC
+	s.ssrcRemap[ssrc] = newSSRC
+	for orig, mapped := range s.ssrcRemap {
+		if mapped == ssrc {
+			s.ssrcRemap[orig] = newSSRC
+		}
+	}
+	delete(s.localSSRCs, ssrc)
+	s.localSSRCs[newSSRC] = true
+
+	// the old SSRC now belongs to the remote source
+	e := new(sourceEntry)
+	if addr != nil {
+		*e.addr(rtcp) = sourceAddr{addr: addr, seen: now}
+	}
+	s.sources[ssrc] = e
+
+	ev := &SSRCEvent{
+		Type:    SSRCEventCollision,
+		SSRC:    ssrc,
+		NewSSRC: newSSRC,
+		Bye:     &Goodbye{Sources: []uint32{ssrc}, Reason: "SSRC collision"},
+		Addr:    addr,
+		RTCP:    rtcp,
+	}
+	return ev, ErrSSRCCollision
+}
+
+// rtcpSenderSSRC returns the SSRC in the first header of the packet m was
+// parsed from
+func rtcpSenderSSRC(m RTCPMessage) (uint32, bool) {
+	switch m := m.(type) {
+	case *SenderReport:
+		return m.SSRC, true
+	case *ReceiverReport:
+		return m.SSRC, true
+	case *SourceDescription:
+		if len(m.Chunks) > 0 {
+			return m.Chunks[0].SSRC, true
+		}
+	case *Goodbye:
+		if len(m.Sources) > 0 {
+			return m.Sources[0], true
+		}
+	case *ApplicationDefined:
+		return m.SSRC, true
+	case *TransportLayerNack:
+		return m.SenderSSRC, true
+	case *TransportCCFeedback:
+		return m.SenderSSRC, true
+	case *PictureLossIndication:
+		return m.SenderSSRC, true
+	case *FullIntraRequest:
+		return m.SenderSSRC, true
+	case *ReceiverEstimatedMaxBitrate:
+		return m.SenderSSRC, true
+	case *ExtendedReport:
+		return m.SSRC, true
+	case *RTCPPacket:
+		if len(m.header.buffer) >= rtcpHeaderSize {
+			return m.header.GetSenderSSRC(), true
+		}
+	}
+	return 0, false
+}
+
+// checkRTCPSources checks the sender SSRC of each message in a compound
+// packet and removes sources that said goodbye
+func (s *RTPSession) checkRTCPSources(msgs []RTCPMessage, addr net.Addr) error {
+	for _, m := range msgs {
+		ssrc, ok := rtcpSenderSSRC(m)
+		if !ok {
+			continue
+		}
+		err := s.checkSource(ssrc, addr, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, m := range msgs {
+		bye, ok := m.(*Goodbye)
+		if !ok {
+			continue
+		}
+		s.mu.Lock()
+		for _, ssrc := range bye.Sources {
+			delete(s.sources, ssrc)
+		}
+		s.mu.Unlock()
+	}
+
+	return nil
+}
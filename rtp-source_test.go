@@ -0,0 +1,257 @@
+package rtp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func newSourceTestSession(t *testing.T) *RTPSession {
+	key := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	salt := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+
+	s := NewRTPSession(true)
+	err := s.SetSRTP(SRTP_AEAD_AES_128_GCM, false, key, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	return s
+}
+
+func encodeTestPacket(t *testing.T, s *RTPSession, ssrc uint32) []byte {
+	p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 1 /*seq*/, 33 /*ts*/, ssrc)
+	data, err := s.Encode(p)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	return data
+}
+
+func TestSourceConflict(t *testing.T) {
+	tx := newSourceTestSession(t)
+	rx := newSourceTestSession(t)
+
+	var events []SSRCEvent
+	rx.SetSSRCEventHandler(func(ev SSRCEvent) { events = append(events, ev) })
+
+	addr1 := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000}
+	addr2 := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 5000}
+
+	_, err := rx.DecodeFrom(encodeTestPacket(t, tx, 44), addr1)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	_, err = rx.DecodeFrom(encodeTestPacket(t, tx, 44), addr1)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = rx.DecodeFrom(encodeTestPacket(t, tx, 44), addr2)
+	assertEqual(t, err, ErrSSRCConflict)
+	assertEqual(t, len(events), 1)
+	assertEqual(t, events[0].Type, SSRCEventConflict)
+	assertEqual(t, events[0].SSRC, uint32(44))
+	assertEqual(t, events[0].Addr, net.Addr(addr2))
+
+	// decoding without an address skips the source table
+	_, err = rx.Decode(encodeTestPacket(t, tx, 44))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+}
+
+func TestSourceCollisionAndLoop(t *testing.T) {
+	local := newSourceTestSession(t)
+	remote := newSourceTestSession(t)
+
+	var events []SSRCEvent
+	local.SetSSRCEventHandler(func(ev SSRCEvent) { events = append(events, ev) })
+
+	addr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000}
+	loopAddr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 9), Port: 6000}
+
+	encodeTestPacket(t, local, 44)
+
+	// a remote source picked the same SSRC as us
+	_, err := local.DecodeFrom(encodeTestPacket(t, remote, 44), addr)
+	assertEqual(t, err, ErrSSRCCollision)
+	assertEqual(t, len(events), 1)
+	assertEqual(t, events[0].Type, SSRCEventCollision)
+	assertEqual(t, events[0].SSRC, uint32(44))
+	assertEqual(t, events[0].Bye.Sources[0], uint32(44))
+	newSSRC := events[0].NewSSRC
+	if newSSRC == 44 {
+		t.Fatalf("new SSRC is the old SSRC")
+	}
+
+	// our packets now go out with the new SSRC
+	p, err := remote.Decode(encodeTestPacket(t, local, 44))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, p.GetSSRC(), newSSRC)
+
+	rr, err := local.NewRtcpRR(44)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, rr.SSRC, newSSRC)
+
+	// the old SSRC now belongs to the remote source
+	_, err = local.DecodeFrom(encodeTestPacket(t, remote, 44), addr)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// our own packet looped back is first seen as a collision and then
+	// recognized as a loop from the same address
+	_, err = local.DecodeFrom(encodeTestPacket(t, local, 44), loopAddr)
+	assertEqual(t, err, ErrSSRCCollision)
+	_, err = local.DecodeFrom(encodeTestPacket(t, local, 44), loopAddr)
+	assertEqual(t, err, ErrSSRCLoop)
+	assertEqual(t, len(events), 3)
+	assertEqual(t, events[2].Type, SSRCEventLoop)
+}
+
+func TestSourceMove(t *testing.T) {
+	tx := newSourceTestSession(t)
+	rx := newSourceTestSession(t)
+	now := time.Unix(1600000000, 0)
+	rx.now = func() time.Time { return now }
+
+	addr1 := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000}
+	addr2 := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 5000}
+
+	_, err := rx.DecodeFrom(encodeTestPacket(t, tx, 44), addr1)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// the old address is still live
+	now = now.Add(sourceMoveTimeout)
+	_, err = rx.DecodeFrom(encodeTestPacket(t, tx, 44), addr2)
+	assertEqual(t, err, ErrSSRCConflict)
+	_, err = rx.DecodeFrom(encodeTestPacket(t, tx, 44), addr1)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// after the old address goes silent the source moves, such as after a
+	// NAT rebinding
+	now = now.Add(sourceMoveTimeout + time.Second)
+	_, err = rx.DecodeFrom(encodeTestPacket(t, tx, 44), addr2)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	_, err = rx.DecodeFrom(encodeTestPacket(t, tx, 44), addr1)
+	assertEqual(t, err, ErrSSRCConflict)
+
+	// the application can move a source at once, such as after an ICE
+	// restart
+	rx.ResetSourceAddr(44)
+	_, err = rx.DecodeFrom(encodeTestPacket(t, tx, 44), addr1)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+}
+
+func TestSourceCollisionWithoutAddr(t *testing.T) {
+	local := newSourceTestSession(t)
+	remote := newSourceTestSession(t)
+
+	var events []SSRCEvent
+	local.SetSSRCEventHandler(func(ev SSRCEvent) { events = append(events, ev) })
+
+	encodeTestPacket(t, local, 44)
+
+	_, err := local.Decode(encodeTestPacket(t, remote, 44))
+	assertEqual(t, err, ErrSSRCCollision)
+	assertEqual(t, len(events), 1)
+	assertEqual(t, events[0].Type, SSRCEventCollision)
+	assertEqual(t, events[0].Addr, nil)
+
+	// the remote source keeps the SSRC and we move to a new one
+	_, err = local.Decode(encodeTestPacket(t, remote, 44))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 1 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+	_, err = local.Encode(p)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, p.GetSSRC(), events[0].NewSSRC)
+}
+
+func TestSourceRTCP(t *testing.T) {
+	tx := newSourceTestSession(t)
+	rx := newSourceTestSession(t)
+
+	rtpAddr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000}
+	rtcpAddr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5001}
+	otherAddr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 5001}
+
+	_, err := rx.DecodeFrom(encodeTestPacket(t, tx, 44), rtpAddr)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	sdes := &SourceDescription{Chunks: []SDESChunk{
+		{SSRC: 44, Items: []SDESItem{{Type: SDESCNAME, Text: "test"}}},
+	}}
+	index := uint32(0)
+	encodeRTCP := func(msgs ...RTCPMessage) []byte {
+		data, err := MarshalRTCPCompound(msgs)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		index++
+		p, _ := NewRTCPCompoundPacket(data, index)
+		data, err = tx.EncodeRTCP(p)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		return data
+	}
+
+	// RTCP is tracked separately from RTP for the same source
+	_, err = rx.DecodeRTCPFrom(encodeRTCP(&ReceiverReport{SSRC: 44}, sdes), rtcpAddr)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	_, err = rx.DecodeRTCPFrom(encodeRTCP(&ReceiverReport{SSRC: 44}, sdes), otherAddr)
+	assertEqual(t, err, ErrSSRCConflict)
+
+	// after a BYE the SSRC may come back from a new address
+	bye := &Goodbye{Sources: []uint32{44}}
+	_, err = rx.DecodeRTCPFrom(encodeRTCP(&ReceiverReport{SSRC: 44}, sdes, bye), rtcpAddr)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	_, err = rx.DecodeRTCPFrom(encodeRTCP(&ReceiverReport{SSRC: 44}, sdes), otherAddr)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+}
+
+func TestRTCPSenderSSRC(t *testing.T) {
+	msgs := []RTCPMessage{
+		&SenderReport{SSRC: 1},
+		&ReceiverReport{SSRC: 2},
+		&SourceDescription{Chunks: []SDESChunk{{SSRC: 3}, {SSRC: 30}}},
+		&Goodbye{Sources: []uint32{4, 40}},
+		&ApplicationDefined{SSRC: 5},
+		&TransportLayerNack{SenderSSRC: 6, MediaSSRC: 60},
+		&PictureLossIndication{SenderSSRC: 7, MediaSSRC: 70},
+		&ExtendedReport{SSRC: 8},
+		NewRTCPPacket(RTCPTypeRTPFB, 2, 9, []byte{0, 0, 0, 0}),
+	}
+	for i, m := range msgs {
+		ssrc, ok := rtcpSenderSSRC(m)
+		assertEqual(t, ok, true)
+		assertEqual(t, ssrc, uint32(i+1))
+	}
+
+	_, ok := rtcpSenderSSRC(&Goodbye{})
+	assertEqual(t, ok, false)
+}
@@ -0,0 +1,357 @@
+package rtp
+
+import (
+	"sort"
+	"time"
+)
+
+// Constants from https://tools.ietf.org/html/rfc3550#appendix-A.1
+const (
+	rtpSeqMod     = 1 << 16
+	maxDropout    = 3000
+	maxMisorder   = 100
+	minSequential = 2
+)
+
+// maxReportBlocks is the most report blocks the 5 bit RC field allows
+const maxReportBlocks = 31
+
+// ReceiverStats is a snapshot of the reception statistics for one remote
+// SSRC as described in https://tools.ietf.org/html/rfc3550#section-6.4.1
+type ReceiverStats struct {
+	SSRC           uint32
+	Valid          bool   // the source has left probation
+	BaseSeq        uint32 // first sequence number after probation
+	ExtHighestSeq  uint32 // cycles in the top 16 bits, highest seq in the bottom
+	Expected       uint32
+	Received       uint32
+	CumulativeLost int32  // clamped to 24 bit signed as in a report block
+	FractionLost   uint8  // fraction lost since the last report, in 1/256
+	Jitter         uint32 // interarrival jitter in RTP timestamp units
+	LastArrival    time.Time
+}
+
+// receiverSource is the per-source state kept by the receiver, following
+// the source struct of https://tools.ietf.org/html/rfc3550#appendix-A.1
+type receiverSource struct {
+	maxSeq        uint16
+	cycles        uint32
+	baseSeq       uint32
+	badSeq        uint32
+	probation     int
+	received      uint32
+	expectedPrior uint32
+	receivedPrior uint32
+
+	haveTransit bool
+	transit     uint32
+	jitter      uint32 // scaled by 16 as in A.8
+
+	lastArrival time.Time
+}
+
+func newReceiverSource(seq uint16) *receiverSource {
+	src := new(receiverSource)
+	src.initSeq(seq)
+	src.maxSeq = seq - 1
+	src.probation = minSequential
+	return src
+}
+
+func (src *receiverSource) initSeq(seq uint16) {
+	src.baseSeq = uint32(seq)
+	src.maxSeq = seq
+	src.badSeq = rtpSeqMod + 1 // so seq == badSeq is false
+	src.cycles = 0
+	src.received = 0
+	src.receivedPrior = 0
+	src.expectedPrior = 0
+}
+
+// updateSeq returns false while the source is on probation or when seq is
+// a large jump that is not yet trusted
+func (src *receiverSource) updateSeq(seq uint16) bool {
+	udelta := seq - src.maxSeq
+
+	if src.probation > 0 {
+		// packet is in sequence
+		if seq == src.maxSeq+1 {
+			src.probation--
+			src.maxSeq = seq
+			if src.probation == 0 {
+				src.initSeq(seq)
+				src.received++
+				return true
+			}
+		} else {
+			src.probation = minSequential - 1
+			src.maxSeq = seq
+		}
+		return false
+	} else if udelta < maxDropout {
+		// in order, with permissible gap
+		if seq < src.maxSeq {
+			// sequence number wrapped, count another 64K cycle
+			src.cycles += rtpSeqMod
+		}
+		src.maxSeq = seq
+	} else if udelta <= rtpSeqMod-maxMisorder {
+		// the sequence number made a very large jump
+		if uint32(seq) == src.badSeq {
+			// two sequential packets, assume the other side restarted
+			// without telling us so just re-sync
+			src.initSeq(seq)
+		} else {
+			src.badSeq = (uint32(seq) + 1) & (rtpSeqMod - 1)
+			return false
+		}
+	}
+	// else duplicate or reordered packet
+
+	src.received++
+	return true
+}
+
+// updateJitter follows https://tools.ietf.org/html/rfc3550#appendix-A.8
+// with arrival and ts both in RTP timestamp units
+func (src *receiverSource) updateJitter(arrival, ts uint32) {
+	transit := arrival - ts
+	if !src.haveTransit {
+		src.haveTransit = true
+		src.transit = transit
+		return
+	}
+
+	d := int32(transit - src.transit)
+	src.transit = transit
+	if d < 0 {
+		d = -d
+	}
+	src.jitter += uint32(d) - ((src.jitter + 8) >> 4)
+}
+
+func (src *receiverSource) extHighestSeq() uint32 {
+	return src.cycles + uint32(src.maxSeq)
+}
+
+func (src *receiverSource) expected() uint32 {
+	return src.extHighestSeq() - src.baseSeq + 1
+}
+
+// cumulativeLost clamps the number of lost packets to 24 bit signed, see
+// https://tools.ietf.org/html/rfc3550#appendix-A.3
+func (src *receiverSource) cumulativeLost() int32 {
+	lost := int64(src.expected()) - int64(src.received)
+	if lost > 0x7FFFFF {
+		lost = 0x7FFFFF
+	} else if lost < -0x800000 {
+		lost = -0x800000
+	}
+	return int32(lost)
+}
+
+func (src *receiverSource) fractionLost() uint8 {
+	expectedInterval := src.expected() - src.expectedPrior
+	receivedInterval := src.received - src.receivedPrior
+	lostInterval := int64(expectedInterval) - int64(receivedInterval)
+	if expectedInterval == 0 || lostInterval <= 0 {
+		return 0
+	}
+	return uint8((lostInterval << 8) / int64(expectedInterval))
+}
+
+func (src *receiverSource) stats(ssrc uint32) ReceiverStats {
+	st := ReceiverStats{
+		SSRC:        ssrc,
+		Valid:       src.probation == 0,
+		LastArrival: src.lastArrival,
+	}
+	if st.Valid {
+		st.BaseSeq = src.baseSeq
+		st.ExtHighestSeq = src.extHighestSeq()
+		st.Expected = src.expected()
+		st.Received = src.received
+		st.CumulativeLost = src.cumulativeLost()
+		st.FractionLost = src.fractionLost()
+		st.Jitter = src.jitter >> 4
+	}
+	return st
+}
+
+// reportBlock forms a report block and starts a new reporting interval
+func (src *receiverSource) reportBlock(ssrc uint32) ReportBlock {
+	st := src.stats(ssrc)
+	src.expectedPrior = src.expected()
+	src.receivedPrior = src.received
+
+	return ReportBlock{
+		SSRC:           ssrc,
+		FractionLost:   st.FractionLost,
+		CumulativeLost: st.CumulativeLost,
+		ExtHighestSeq:  st.ExtHighestSeq,
+		Jitter:         st.Jitter,
+	}
+}
+
+// rtpUnits converts a wall clock time to RTP timestamp units at rate Hz.
+// Only differences between the results are meaningful.
+func rtpUnits(t time.Time, rate uint32) uint32 {
+	secs := t.Unix() * int64(rate)
+	frac := int64(t.Nanosecond()) * int64(rate) / int64(time.Second)
+	return uint32(secs + frac)
+}
+
+// updateReceiverStats records the arrival of a decoded packet
+func (s *RTPSession) updateReceiverStats(p *RTPPacket) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ssrc := p.GetSSRC()
+	src, ok := s.receivers[ssrc]
+	if !ok {
+		src = newReceiverSource(p.GetSeq())
+		s.receivers[ssrc] = src
+	}
+	src.lastArrival = now
+
+	if !src.updateSeq(p.GetSeq()) {
+		return
+	}
+
+	rate, ok := s.clockRates[p.GetPT()]
+	if !ok {
+		return
+	}
+	src.updateJitter(rtpUnits(now, rate), p.GetTimestamp())
+}
+
+// SetClockRate sets the RTP timestamp rate in Hz for payload type pt, which
+// is needed to compute the interarrival jitter of received packets
+func (s *RTPSession) SetClockRate(pt int8, rate uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clockRates[pt] = rate
+}
+
+// GetReceiverStats returns a snapshot of the reception statistics for ssrc
+func (s *RTPSession) GetReceiverStats(ssrc uint32) (ReceiverStats, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, ok := s.receivers[ssrc]
+	if !ok {
+		return ReceiverStats{}, false
+	}
+	return src.stats(ssrc), true
+}
+
+// GetReceiverSSRCs returns the remote SSRCs that packets have been decoded
+// from, in ascending order
+func (s *RTPSession) GetReceiverSSRCs() []uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ssrcs := make([]uint32, 0, len(s.receivers))
+	for ssrc := range s.receivers {
+		ssrcs = append(ssrcs, ssrc)
+	}
+	sort.Slice(ssrcs, func(i, j int) bool { return ssrcs[i] < ssrcs[j] })
+	return ssrcs
+}
+
+// reportBlocks forms a report block for each valid remote source and starts
+// a new reporting interval for each of them. The LSR and DLSR refer to the
+// last SR received from the source, relative to now.
+func (s *RTPSession) reportBlocks(now time.Time) []ReportBlock {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ssrcs := make([]uint32, 0, len(s.receivers))
+	for ssrc, src := range s.receivers {
+		if src.probation == 0 {
+			ssrcs = append(ssrcs, ssrc)
+		}
+	}
+	sort.Slice(ssrcs, func(i, j int) bool { return ssrcs[i] < ssrcs[j] })
+	if len(ssrcs) > maxReportBlocks {
+		ssrcs = ssrcs[:maxReportBlocks]
+	}
+
+	blocks := make([]ReportBlock, 0, len(ssrcs))
+	for _, ssrc := range ssrcs {
+		rb := s.receivers[ssrc].reportBlock(ssrc)
+		if sr, ok := s.remoteSRs[ssrc]; ok {
+			rb.LSR = sr.ntp
+			rb.DLSR = toShortNTP(now.Sub(sr.arrival))
+		}
+		blocks = append(blocks, rb)
+	}
+	return blocks
+}
+
+// SenderStats is a snapshot of what has been sent from one local SSRC
+type SenderStats struct {
+	SSRC        uint32
+	PacketCount uint32
+	OctetCount  uint32 // payload octets, not including headers or padding
+	LastRTPTime uint32
+	LastTime    time.Time // wall clock time LastRTPTime was sent at
+}
+
+// senderSource is the per-source state kept for each local SSRC
+type senderSource struct {
+	packetCount uint32
+	octetCount  uint32
+	pt          int8
+	lastRTPTime uint32
+	lastTime    time.Time
+}
+
+// rtpTime extrapolates the RTP timestamp for the wall clock time t from the
+// last packet sent
+func (src *senderSource) rtpTime(t time.Time, rate uint32) uint32 {
+	d := t.Sub(src.lastTime)
+	secs := int64(d / time.Second)
+	frac := int64(d%time.Second) * int64(rate) / int64(time.Second)
+	return src.lastRTPTime + uint32(secs*int64(rate)+frac)
+}
+
+// updateSenderStats records a packet sent from ssrc
+func (s *RTPSession) updateSenderStats(ssrc uint32, pt int8, ts uint32, payloadLen int) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, ok := s.senders[ssrc]
+	if !ok {
+		src = new(senderSource)
+		s.senders[ssrc] = src
+	}
+	src.packetCount++
+	src.octetCount += uint32(payloadLen)
+	src.pt = pt
+	src.lastRTPTime = ts
+	src.lastTime = now
+}
+
+// GetSenderStats returns a snapshot of what has been sent from ssrc
+func (s *RTPSession) GetSenderStats(ssrc uint32) (SenderStats, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, ok := s.senders[ssrc]
+	if !ok {
+		return SenderStats{}, false
+	}
+	return SenderStats{
+		SSRC:        ssrc,
+		PacketCount: src.packetCount,
+		OctetCount:  src.octetCount,
+		LastRTPTime: src.lastRTPTime,
+		LastTime:    src.lastTime,
+	}, true
+}
@@ -0,0 +1,208 @@
+package rtp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReceiverSourceProbation(t *testing.T) {
+	src := newReceiverSource(100)
+	assertEqual(t, src.updateSeq(100), false)
+	assertEqual(t, src.probation, 1)
+
+	// out of sequence restarts probation
+	assertEqual(t, src.updateSeq(105), false)
+	assertEqual(t, src.probation, 1)
+
+	assertEqual(t, src.updateSeq(106), true)
+	assertEqual(t, src.probation, 0)
+	assertEqual(t, src.baseSeq, uint32(106))
+	assertEqual(t, src.received, uint32(1))
+	assertEqual(t, src.expected(), uint32(1))
+}
+
+func TestReceiverSourceLoss(t *testing.T) {
+	src := newReceiverSource(0xfffd)
+	src.updateSeq(0xfffd)
+	src.updateSeq(0xfffe)
+
+	// wrap with a gap of two lost packets
+	for _, seq := range []uint16{0xffff, 2, 3, 4} {
+		assertEqual(t, src.updateSeq(seq), true)
+	}
+	assertEqual(t, src.extHighestSeq(), uint32(0x10004))
+	assertEqual(t, src.expected(), uint32(7))
+	assertEqual(t, src.received, uint32(5))
+	assertEqual(t, src.cumulativeLost(), int32(2))
+	assertEqual(t, src.fractionLost(), uint8(2*256/7))
+
+	rb := src.reportBlock(7)
+	assertEqual(t, rb.FractionLost, uint8(2*256/7))
+	assertEqual(t, rb.CumulativeLost, int32(2))
+	assertEqual(t, rb.ExtHighestSeq, uint32(0x10004))
+
+	// a new interval with no loss, then a duplicate
+	src.updateSeq(5)
+	src.updateSeq(5)
+	assertEqual(t, src.fractionLost(), uint8(0))
+	assertEqual(t, src.cumulativeLost(), int32(1))
+}
+
+func TestReceiverSourceRestart(t *testing.T) {
+	src := newReceiverSource(10)
+	src.updateSeq(10)
+	src.updateSeq(11)
+
+	// a single large jump is ignored
+	assertEqual(t, src.updateSeq(30000), false)
+	assertEqual(t, src.extHighestSeq(), uint32(11))
+
+	// two sequential packets after the jump re-sync the source
+	assertEqual(t, src.updateSeq(30001), true)
+	assertEqual(t, src.baseSeq, uint32(30001))
+	assertEqual(t, src.received, uint32(1))
+	assertEqual(t, src.cumulativeLost(), int32(0))
+}
+
+func TestReceiverSourceJitter(t *testing.T) {
+	src := newReceiverSource(0)
+
+	// transit alternates by 160 units so the jitter converges towards 160
+	for i := 0; i < 200; i++ {
+		arrival := uint32(i * 160)
+		if i%2 == 1 {
+			arrival += 160
+		}
+		src.updateJitter(arrival, uint32(i*160))
+	}
+	jitter := src.jitter >> 4
+	if jitter < 150 || jitter > 160 {
+		t.Errorf("jitter %d not close to 160", jitter)
+	}
+
+	// constant transit time decays the jitter
+	for i := 0; i < 500; i++ {
+		src.updateJitter(uint32(i*160)+1000, uint32(i*160))
+	}
+	assertEqual(t, src.jitter>>4, uint32(0))
+}
+
+func TestSessionReceiverStats(t *testing.T) {
+	key := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	salt := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+	now := time.Unix(1600000000, 0)
+
+	tx := NewRTPSession(false)
+	err := tx.SetSRTP(SRTP_AEAD_AES_128_GCM, false, key, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	rx := NewRTPSession(false)
+	err = rx.SetSRTP(SRTP_AEAD_AES_128_GCM, false, key, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	rx.SetClockRate(8, 8000)
+	rx.now = func() time.Time { return now }
+
+	_, ok := rx.GetReceiverStats(44)
+	assertEqual(t, ok, false)
+
+	// 20ms packets with seq 3 lost and the last one arriving 10ms late
+	for _, seq := range []uint16{1, 2, 4, 5, 6} {
+		p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, seq, uint32(seq)*160, 44 /*ssrc*/)
+		tx.seq = seq
+		data, err := tx.Encode(p)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		now = time.Unix(1600000000, 0).Add(time.Duration(seq) * 20 * time.Millisecond)
+		if seq == 6 {
+			now = now.Add(10 * time.Millisecond)
+		}
+		_, err = rx.Decode(data)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+
+	assertEqual(t, len(rx.GetReceiverSSRCs()), 1)
+	st, ok := rx.GetReceiverStats(44)
+	assertEqual(t, ok, true)
+	assertEqual(t, st.Valid, true)
+	assertEqual(t, st.BaseSeq, uint32(2))
+	assertEqual(t, st.ExtHighestSeq, uint32(6))
+	assertEqual(t, st.Expected, uint32(5))
+	assertEqual(t, st.Received, uint32(4))
+	assertEqual(t, st.CumulativeLost, int32(1))
+	assertEqual(t, st.FractionLost, uint8(256/5))
+	assertEqual(t, st.Jitter, uint32(80/16))
+	assertEqual(t, st.LastArrival, now)
+
+	rr, err := rx.NewRtcpRR(55)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(rr.Reports), 1)
+	assertEqual(t, rr.Reports[0].SSRC, uint32(44))
+	assertEqual(t, rr.Reports[0].FractionLost, uint8(256/5))
+
+	// the report starts a new interval
+	st, _ = rx.GetReceiverStats(44)
+	assertEqual(t, st.FractionLost, uint8(0))
+	assertEqual(t, st.CumulativeLost, int32(1))
+}
+
+func TestSessionSenderReport(t *testing.T) {
+	key := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	salt := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+	start := time.Unix(1600000000, 0)
+	now := start
+
+	tx := NewRTPSession(true)
+	err := tx.SetSRTP(SRTP_AEAD_AES_128_GCM, true, key, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	tx.now = func() time.Time { return now }
+
+	_, err = tx.NewRtcpSR(44)
+	if err == nil {
+		t.Errorf("expected error for ssrc with no packets sent")
+	}
+
+	for i := 0; i < 3; i++ {
+		now = start.Add(time.Duration(i) * 20 * time.Millisecond)
+		p := NewRTPPacket([]byte{1, 2, 3, 4, 5}, 8 /*pt*/, 0 /*seq*/, uint32(1000+i*160), 44 /*ssrc*/)
+		_, err = tx.Encode(p)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+
+	st, ok := tx.GetSenderStats(44)
+	assertEqual(t, ok, true)
+	assertEqual(t, st.PacketCount, uint32(3))
+	assertEqual(t, st.OctetCount, uint32(15))
+	assertEqual(t, st.LastRTPTime, uint32(1320))
+	assertEqual(t, st.LastTime, now)
+
+	_, err = tx.NewRtcpSR(44)
+	if err == nil {
+		t.Errorf("expected error without a clock rate")
+	}
+
+	tx.SetClockRate(8, 8000)
+	now = now.Add(1500 * time.Millisecond)
+	sr, err := tx.NewRtcpSR(44)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, sr.SSRC, uint32(44))
+	assertEqual(t, sr.NTPTime, toNTP(now))
+	assertEqual(t, sr.RTPTime, uint32(1320+12000))
+	assertEqual(t, sr.PacketCount, uint32(3))
+	assertEqual(t, sr.OctetCount, uint32(15))
+	assertEqual(t, len(sr.Reports), 0)
+}
@@ -0,0 +1,189 @@
+package rtp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash"
+	"sync"
+)
+
+// srtcpIndexMask keeps the SRTCP index to 31 bits
+const srtcpIndexMask = 0x7fffffff
+
+// cryptoContext is the per-SSRC state of
+// https://tools.ietf.org/html/rfc3711#section-3.2.3. The session keys are
+// derived from the master key without the SSRC so they are shared by all
+// the contexts of a session and kept in the RTPSession.
+type cryptoContext struct {
+	// ROC and s_l of the stream
+	roc rocState
+
+	// sending only
+	seq        uint16 // next sequence number when rewriting
+	srtcpIndex uint32 // next SRTCP index
+
+	// receiving only
+	rtpReplay  *replayWindow
+	rtcpReplay *replayWindow
+}
+
+// txContext returns the context for the local ssrc, creating it with a
+// random initial sequence number. It must be called with s.mu held.
+func (s *RTPSession) txContext(ssrc uint32) (*cryptoContext, error) {
+	ctx, ok := s.txContexts[ssrc]
+	if ok {
+		return ctx, nil
+	}
+
+	ctx = &cryptoContext{seq: s.seq}
+	s.txContexts[ssrc] = ctx
+
+	// pick the initial sequence number for the next stream
+	randBytes := make([]byte, 2)
+	_, err := rand.Read(randBytes)
+	if err != nil {
+		return nil, err
+	}
+	s.seq = binary.BigEndian.Uint16(randBytes) & 0x7FFF
+
+	return ctx, nil
+}
+
+// rxContext returns the context for the remote ssrc, creating it on first
+// use. Contexts are only created for authenticated packets so forged packets
+// cannot grow the table. It must be called with s.mu held.
+func (s *RTPSession) rxContext(ssrc uint32) *cryptoContext {
+	ctx, ok := s.rxContexts[ssrc]
+	if !ok {
+		ctx = &cryptoContext{
+			rtpReplay:  newReplayWindow(s.replaySize),
+			rtcpReplay: newReplayWindow(s.replaySize),
+		}
+		s.rxContexts[ssrc] = ctx
+	}
+	return ctx
+}
+
+// macPool hands out HMAC-SHA1s keyed with one auth key. An HMAC has state
+// while a packet is hashed so each packet takes its own from the pool.
+type macPool struct {
+	pool sync.Pool
+}
+
+func newMACPool(key []byte) *macPool {
+	p := &macPool{}
+	p.pool.New = func() interface{} {
+		return hmac.New(sha1.New, key)
+	}
+	return p
+}
+
+// get returns a reset HMAC, which goes back to the pool with put
+func (p *macPool) get() hash.Hash {
+	mac := p.pool.Get().(hash.Hash)
+	mac.Reset()
+	return mac
+}
+
+func (p *macPool) put(mac hash.Hash) {
+	p.pool.Put(mac)
+}
+
+// nextTxROC rewrites the sequence number of p if the session does so and
+// returns the ROC to encrypt p with
+func (s *RTPSession) nextTxROC(p *RTPPacket) (uint32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, err := s.txContext(p.GetSSRC())
+	if err != nil {
+		return 0, err
+	}
+
+	if s.rewriteSeq {
+		err = p.SetSeq(ctx.seq)
+		if err != nil {
+			return 0, err
+		}
+		ctx.seq++
+	}
+
+	index := ctx.roc.guessIndex(p.GetSeq())
+	ctx.roc.commit(index)
+	return uint32(index >> 16), nil
+}
+
+// checkRTPIndex estimates the packet index of a packet from ssrc and checks
+// it against the replay window. A new ssrc has no context until the packet
+// is authenticated and committed.
+func (s *RTPSession) checkRTPIndex(ssrc uint32, seq uint16) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, ok := s.rxContexts[ssrc]
+	if !ok {
+		return uint64(seq), nil
+	}
+	index := ctx.roc.guessIndex(seq)
+	return index, ctx.rtpReplay.check(index)
+}
+
+// commitRTPIndex records the index of an authenticated packet from ssrc. It
+// fails if a copy of the packet was committed since checkRTPIndex.
+func (s *RTPSession) commitRTPIndex(ssrc uint32, index uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx := s.rxContext(ssrc)
+	err := ctx.rtpReplay.commit(index)
+	if err != nil {
+		return err
+	}
+	ctx.roc.commit(index)
+	return nil
+}
+
+// checkRTCPIndex checks the SRTCP index of a packet from ssrc against the
+// replay window
+func (s *RTPSession) checkRTCPIndex(ssrc uint32, index uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, ok := s.rxContexts[ssrc]
+	if !ok {
+		return nil
+	}
+	return ctx.rtcpReplay.check(uint64(index))
+}
+
+// commitRTCPIndex records the SRTCP index of an authenticated packet. It
+// fails if a copy of the packet was committed since checkRTCPIndex.
+func (s *RTPSession) commitRTCPIndex(ssrc uint32, index uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rxContext(ssrc).rtcpReplay.commit(uint64(index))
+}
+
+// NewRTCPCompoundPacket forms a compound packet from buffer with the next
+// SRTCP index of the sender SSRC in its first header
+func (s *RTPSession) NewRTCPCompoundPacket(buffer []byte) (*RTCPCompoundPacket, error) {
+	if len(buffer) < rtcpHeaderSize {
+		return nil, ErrRTCPTooShort
+	}
+	ssrc := binary.BigEndian.Uint32(buffer[4:])
+
+	s.mu.Lock()
+	ctx, err := s.txContext(ssrc)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	index := ctx.srtcpIndex
+	ctx.srtcpIndex = (ctx.srtcpIndex + 1) & srtcpIndexMask
+	s.mu.Unlock()
+
+	return NewRTCPCompoundPacket(buffer, index)
+}
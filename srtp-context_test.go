@@ -0,0 +1,240 @@
+package rtp
+
+import (
+	"sync"
+	"testing"
+)
+
+func newContextSessions(t *testing.T, rewriteSeq bool) (*RTPSession, *RTPSession) {
+	key := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	salt := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+
+	tx := NewRTPSession(rewriteSeq)
+	err := tx.SetSRTP(SRTP_AES128_CM_HMAC_SHA1_80, false, key, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	rx := NewRTPSession(false)
+	err = rx.SetSRTP(SRTP_AES128_CM_HMAC_SHA1_80, false, key, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	return tx, rx
+}
+
+func TestContextROCPerSSRC(t *testing.T) {
+	tx, rx := newContextSessions(t, false)
+
+	// ssrc 44 wraps while ssrc 55 does not
+	seqs := []uint16{65534, 65535, 0, 1}
+	for i, seq := range seqs {
+		for _, ssrc := range []uint32{44, 55} {
+			if ssrc == 55 {
+				seq = uint16(100 + i)
+			}
+			p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, seq, 33 /*ts*/, ssrc)
+			data, err := tx.Encode(p)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+			p, err = rx.Decode(data)
+			if err != nil {
+				t.Fatalf("ssrc %d seq %d: %s", ssrc, seq, err.Error())
+			}
+			compareByteArrays(t, p.GetPayload(), []byte{1, 2, 3, 4})
+		}
+	}
+
+	assertEqual(t, tx.txContexts[44].roc.roc, uint32(1))
+	assertEqual(t, tx.txContexts[55].roc.roc, uint32(0))
+	assertEqual(t, rx.rxContexts[44].roc.roc, uint32(1))
+	assertEqual(t, rx.rxContexts[55].roc.roc, uint32(0))
+}
+
+func TestContextRewriteSeqPerSSRC(t *testing.T) {
+	tx, _ := newContextSessions(t, true)
+	tx.seq = 100
+
+	var seq44, seq55 []uint16
+	for i := 0; i < 3; i++ {
+		p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 0 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+		_, err := tx.Encode(p)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		seq44 = append(seq44, p.GetSeq())
+
+		p = NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 0 /*seq*/, 33 /*ts*/, 55 /*ssrc*/)
+		_, err = tx.Encode(p)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		seq55 = append(seq55, p.GetSeq())
+	}
+
+	// each SSRC numbers its own packets without gaps
+	assertEqual(t, seq44[0], uint16(100))
+	for i := 1; i < 3; i++ {
+		assertEqual(t, seq44[i], seq44[i-1]+1)
+		assertEqual(t, seq55[i], seq55[i-1]+1)
+	}
+}
+
+func TestContextSRTCPIndex(t *testing.T) {
+	tx, rx := newContextSessions(t, false)
+
+	encode := func(ssrc uint32) []byte {
+		rr := &ReceiverReport{SSRC: ssrc}
+		sdes := &SourceDescription{Chunks: []SDESChunk{
+			{SSRC: ssrc, Items: []SDESItem{{Type: SDESCNAME, Text: "test"}}},
+		}}
+		plain, err := MarshalRTCPCompound([]RTCPMessage{rr, sdes})
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		cp, err := tx.NewRTCPCompoundPacket(plain)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		data, err := tx.EncodeRTCP(cp)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		return data
+	}
+
+	// both senders start at index 0 and neither is a replay of the other
+	for i := 0; i < 3; i++ {
+		for _, ssrc := range []uint32{44, 55} {
+			_, err := rx.DecodeRTCP(encode(ssrc))
+			if err != nil {
+				t.Fatalf("ssrc %d: %s", ssrc, err.Error())
+			}
+		}
+	}
+	assertEqual(t, tx.txContexts[44].srtcpIndex, uint32(3))
+	assertEqual(t, tx.txContexts[55].srtcpIndex, uint32(3))
+	assertEqual(t, rx.rxContexts[44].rtcpReplay.max, uint64(2))
+	assertEqual(t, rx.rxContexts[55].rtcpReplay.max, uint64(2))
+
+	_, err := tx.NewRTCPCompoundPacket([]byte{0x80, 201, 0, 1})
+	assertEqual(t, err, ErrRTCPTooShort)
+}
+
+func TestContextForgedPackets(t *testing.T) {
+	tx, rx := newContextSessions(t, false)
+
+	for i := 0; i < 100; i++ {
+		ssrc := uint32(1000 + i)
+
+		p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 1 /*seq*/, 33 /*ts*/, ssrc)
+		data, err := tx.Encode(p)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		data[len(data)-1] ^= 1
+		_, err = rx.Decode(data)
+		if err == nil {
+			t.Fatalf("expected authentication failure")
+		}
+
+		rr := &ReceiverReport{SSRC: ssrc}
+		sdes := &SourceDescription{Chunks: []SDESChunk{
+			{SSRC: ssrc, Items: []SDESItem{{Type: SDESCNAME, Text: "test"}}},
+		}}
+		plain, _ := MarshalRTCPCompound([]RTCPMessage{rr, sdes})
+		cp, err := tx.NewRTCPCompoundPacket(plain)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		data, err = tx.EncodeRTCP(cp)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		data[len(data)-1] ^= 1
+		_, err = rx.DecodeRTCP(data)
+		if err == nil {
+			t.Fatalf("expected authentication failure")
+		}
+	}
+
+	// packets that fail authentication do not create contexts
+	assertEqual(t, len(rx.rxContexts), 0)
+}
+
+func TestContextRekey(t *testing.T) {
+	tx, rx := newContextSessions(t, false)
+
+	exchange := func(seq uint16) {
+		p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, seq, 33 /*ts*/, 44 /*ssrc*/)
+		data, err := tx.Encode(p)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		_, err = rx.Decode(data)
+		if err != nil {
+			t.Fatalf("seq %d: %s", seq, err.Error())
+		}
+	}
+	exchange(1)
+
+	// streams already seen follow the new master key
+	key := []byte{16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+	salt := []byte{14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+	for _, s := range []*RTPSession{tx, rx} {
+		err := s.SetSRTP(SRTP_AES128_CM_HMAC_SHA1_80, false, key, salt)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+	exchange(2)
+}
+
+func TestContextConcurrentSSRC(t *testing.T) {
+	tx, rx := newContextSessions(t, true)
+
+	const senders = 8
+	const packets = 100
+	encoded := make(chan []byte, senders*packets)
+
+	// every sender shares ssrc 44 so its packets are authenticated at once
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < packets; j++ {
+				p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 0 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+				data, err := tx.Encode(p)
+				if err != nil {
+					t.Errorf(err.Error())
+					return
+				}
+				encoded <- data
+			}
+		}()
+	}
+	wg.Wait()
+	close(encoded)
+
+	// the receivers also run at once, so packets arrive far out of order
+	err := rx.SetReplayWindow(senders * packets)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for data := range encoded {
+				p, err := rx.Decode(data)
+				if err != nil {
+					t.Errorf(err.Error())
+					return
+				}
+				compareByteArrays(t, p.GetPayload(), []byte{1, 2, 3, 4})
+			}
+		}()
+	}
+	wg.Wait()
+}
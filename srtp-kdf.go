@@ -16,6 +16,10 @@ const (
 	KCs byte = 0x05
 )
 
+// authKeySize is the HMAC-SHA1 key size for the AES-CM profiles from
+// https://tools.ietf.org/html/rfc3711#section-8.2
+const authKeySize = 20
+
 type KDF struct {
 	masterSalt []byte
 	block      cipher.Block
@@ -71,6 +75,9 @@ func (kdf KDF) Derive(label byte, index uint64, size int) []byte {
 func (kdf KDF) DeriveForStream(cipher CipherID) ([]byte, []byte, []byte, []byte, error) {
 	var keySize, saltSize int
 	switch cipher {
+	case SRTP_AES128_CM_HMAC_SHA1_80, SRTP_AES128_CM_HMAC_SHA1_32:
+		keySize = 16
+		saltSize = 14
 	case SRTP_AEAD_AES_128_GCM:
 		keySize = 16
 		saltSize = 12
@@ -88,3 +95,18 @@ func (kdf KDF) DeriveForStream(cipher CipherID) ([]byte, []byte, []byte, []byte,
 
 	return rtpKey, rtpSalt, rtcpKey, rtcpSalt, nil
 }
+
+// DeriveAuthForStream derives the HMAC-SHA1 authentication keys for the
+// AES-CM profiles, which the AEAD profiles do not need
+func (kdf KDF) DeriveAuthForStream(cipher CipherID) ([]byte, []byte, error) {
+	switch cipher {
+	case SRTP_AES128_CM_HMAC_SHA1_80, SRTP_AES128_CM_HMAC_SHA1_32:
+	default:
+		return nil, nil, fmt.Errorf("Unsupported cipher: %04x", cipher)
+	}
+
+	rtpAuthKey := kdf.Derive(Ka, 0, authKeySize)
+	rtcpAuthKey := kdf.Derive(KCa, 0, authKeySize)
+
+	return rtpAuthKey, rtcpAuthKey, nil
+}
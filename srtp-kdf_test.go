@@ -37,3 +37,29 @@ func TestKDF(t *testing.T) {
 	}
 
 }
+
+// From https://tools.ietf.org/html/rfc3711#appendix-B.3
+func TestKDFAuth(t *testing.T) {
+	masterKey, _ := hex.DecodeString("E1F97A0D3E018BE0D64FA32C06DE4139")
+	masterSalt, _ := hex.DecodeString("0EC675AD498AFEEBB6960B3AABE6")
+	authKey, _ := hex.DecodeString("CEBE321F6FF7716B6FD4AB49AF256A156D38BAA4")
+
+	kdf, err := NewKDF(masterKey, masterSalt)
+	if err != nil {
+		t.Fatalf("Error creating KDF")
+	}
+
+	rtpAuthKey, rtcpAuthKey, err := kdf.DeriveAuthForStream(SRTP_AES128_CM_HMAC_SHA1_80)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if !bytes.Equal(rtpAuthKey, authKey) {
+		t.Fatalf("Incorrect auth key: %x != %x", rtpAuthKey, authKey)
+	}
+	assertEqual(t, len(rtcpAuthKey), 20)
+
+	_, _, err = kdf.DeriveAuthForStream(SRTP_AEAD_AES_128_GCM)
+	if err == nil {
+		t.Fatalf("expected error for AEAD cipher")
+	}
+}
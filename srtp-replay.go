@@ -0,0 +1,113 @@
+package rtp
+
+import (
+	"errors"
+)
+
+// defaultReplayWindow is the minimum window size from
+// https://tools.ietf.org/html/rfc3711#section-3.3.2
+const defaultReplayWindow = 64
+
+// Errors returned by Decode and DecodeRTCP for packets rejected by the
+// replay window
+var (
+	ErrReplayDuplicate = errors.New("srtp: packet index already received")
+	ErrReplayTooOld    = errors.New("srtp: packet index too old for replay window")
+)
+
+// replayWindow is a sliding window over packet indexes as in
+// https://tools.ietf.org/html/rfc3711#section-3.3.2. The bits are a ring
+// indexed by the packet index modulo its size.
+type replayWindow struct {
+	size        uint64
+	bits        []uint64
+	initialized bool
+	max         uint64
+}
+
+func newReplayWindow(size uint64) *replayWindow {
+	return &replayWindow{
+		size: size,
+		bits: make([]uint64, (size+63)/64),
+	}
+}
+
+func (w *replayWindow) ringSize() uint64 {
+	return uint64(len(w.bits)) * 64
+}
+
+func (w *replayWindow) getBit(index uint64) bool {
+	i := index % w.ringSize()
+	return w.bits[i/64]&(1<<(i%64)) != 0
+}
+
+func (w *replayWindow) setBit(index uint64, v bool) {
+	i := index % w.ringSize()
+	if v {
+		w.bits[i/64] |= 1 << (i % 64)
+	} else {
+		w.bits[i/64] &^= 1 << (i % 64)
+	}
+}
+
+// check returns an error if index has already been received or is behind
+// the window. It does not change the window.
+func (w *replayWindow) check(index uint64) error {
+	if !w.initialized || index > w.max {
+		return nil
+	}
+	if w.max-index >= w.size {
+		return ErrReplayTooOld
+	}
+	if w.getBit(index) {
+		return ErrReplayDuplicate
+	}
+	return nil
+}
+
+// commit marks index as received. It must only be called once the packet
+// has been authenticated. The window is checked again since another copy of
+// the packet may have been committed after index was checked.
+func (w *replayWindow) commit(index uint64) error {
+	if !w.initialized {
+		w.initialized = true
+		w.max = index
+		w.setBit(index, true)
+		return nil
+	}
+
+	err := w.check(index)
+	if err != nil {
+		return err
+	}
+
+	if index > w.max {
+		if index-w.max >= w.ringSize() {
+			for i := range w.bits {
+				w.bits[i] = 0
+			}
+		} else {
+			for i := w.max + 1; i < index; i++ {
+				w.setBit(i, false)
+			}
+		}
+		w.max = index
+	}
+	w.setBit(index, true)
+	return nil
+}
+
+// SetReplayWindow sets the number of packets the replay window of each SSRC
+// covers, for SRTP and SRTCP. It applies to crypto contexts created after the
+// call.
+func (s *RTPSession) SetReplayWindow(size int) error {
+	if size <= 0 {
+		return errors.New("srtp: replay window size must be positive")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.replaySize = uint64(size)
+	return nil
+}
@@ -0,0 +1,139 @@
+package rtp
+
+import (
+	"testing"
+)
+
+func TestReplayWindow(t *testing.T) {
+	w := newReplayWindow(64)
+
+	assertEqual(t, w.check(1000), nil)
+	w.commit(1000)
+	assertEqual(t, w.check(1000), ErrReplayDuplicate)
+
+	// reordered packets inside the window are accepted once
+	assertEqual(t, w.check(990), nil)
+	w.commit(990)
+	assertEqual(t, w.check(990), ErrReplayDuplicate)
+	assertEqual(t, w.check(937), nil)
+	assertEqual(t, w.check(936), ErrReplayTooOld)
+
+	// moving the window forward forgets what fell out of it
+	w.commit(1050)
+	assertEqual(t, w.check(1000), ErrReplayDuplicate)
+	assertEqual(t, w.check(990), ErrReplayDuplicate)
+	assertEqual(t, w.check(987), nil)
+	assertEqual(t, w.check(986), ErrReplayTooOld)
+	assertEqual(t, w.check(1049), nil)
+
+	// a packet checked twice before either copy is committed is taken once
+	assertEqual(t, w.check(1051), nil)
+	assertEqual(t, w.check(1051), nil)
+	assertEqual(t, w.commit(1051), nil)
+	assertEqual(t, w.commit(1051), ErrReplayDuplicate)
+
+	// a jump larger than the window clears it
+	w.commit(5000)
+	assertEqual(t, w.check(4999), nil)
+	assertEqual(t, w.check(5000), ErrReplayDuplicate)
+	assertEqual(t, w.check(1050), ErrReplayTooOld)
+}
+
+func TestReplayWindowLarge(t *testing.T) {
+	w := newReplayWindow(100)
+	for i := uint64(0); i < 300; i += 2 {
+		w.commit(i)
+	}
+	assertEqual(t, w.check(298), ErrReplayDuplicate)
+	assertEqual(t, w.check(200), ErrReplayDuplicate)
+	assertEqual(t, w.check(199), nil)
+	assertEqual(t, w.check(198), ErrReplayTooOld)
+	assertEqual(t, w.check(297), nil)
+}
+
+func TestSessionReplay(t *testing.T) {
+	key := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	salt := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+
+	tx := NewRTPSession(false)
+	err := tx.SetSRTP(SRTP_AES128_CM_HMAC_SHA1_80, false, key, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	rx := NewRTPSession(false)
+	err = rx.SetSRTP(SRTP_AES128_CM_HMAC_SHA1_80, false, key, salt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, rx.SetReplayWindow(0) != nil, true)
+	err = rx.SetReplayWindow(100)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	encode := func(seq uint16) []byte {
+		p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, seq, 33 /*ts*/, 44 /*ssrc*/)
+		data, err := tx.Encode(p)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		return data
+	}
+
+	old := encode(10)
+	data := encode(200)
+	replay := append([]byte{}, data...)
+
+	// a packet that fails authentication does not move the window
+	forged := append([]byte{}, data...)
+	forged[len(forged)-1] ^= 1
+	_, err = rx.Decode(forged)
+	if err == nil {
+		t.Fatalf("expected authentication failure")
+	}
+
+	_, err = rx.Decode(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	_, err = rx.Decode(replay)
+	assertEqual(t, err, ErrReplayDuplicate)
+	_, err = rx.Decode(old)
+	assertEqual(t, err, ErrReplayTooOld)
+	_, err = rx.Decode(encode(150))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// SRTCP uses the SRTCP index
+	rr := &ReceiverReport{SSRC: 44}
+	sdes := &SourceDescription{Chunks: []SDESChunk{
+		{SSRC: 44, Items: []SDESItem{{Type: SDESCNAME, Text: "test"}}},
+	}}
+	plain, _ := MarshalRTCPCompound([]RTCPMessage{rr, sdes})
+	cp, _ := NewRTCPCompoundPacket(append([]byte{}, plain...), 7)
+	data, err = tx.EncodeRTCP(cp)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	replay = append([]byte{}, data...)
+
+	_, err = rx.DecodeRTCP(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	_, err = rx.DecodeRTCP(replay)
+	assertEqual(t, err, ErrReplayDuplicate)
+
+	// copies that both pass the check before either is committed
+	_, err = rx.checkRTPIndex(44, 300)
+	assertEqual(t, err, nil)
+	_, err = rx.checkRTPIndex(44, 300)
+	assertEqual(t, err, nil)
+	assertEqual(t, rx.commitRTPIndex(44, 300), nil)
+	assertEqual(t, rx.commitRTPIndex(44, 300), ErrReplayDuplicate)
+	assertEqual(t, rx.checkRTCPIndex(44, 8), nil)
+	assertEqual(t, rx.checkRTCPIndex(44, 8), nil)
+	assertEqual(t, rx.commitRTCPIndex(44, 8), nil)
+	assertEqual(t, rx.commitRTCPIndex(44, 8), ErrReplayDuplicate)
+}
@@ -0,0 +1,40 @@
+package rtp
+
+// rocState is the receiver's rollover counter and highest sequence number
+// for one SSRC, s_l in https://tools.ietf.org/html/rfc3711#section-3.3.1
+type rocState struct {
+	initialized bool
+	roc         uint32
+	sl          uint16
+}
+
+// guessIndex estimates the 48 bit packet index for seq following
+// https://tools.ietf.org/html/rfc3711#appendix-A
+func (r *rocState) guessIndex(seq uint16) uint64 {
+	if !r.initialized {
+		return uint64(seq)
+	}
+
+	v := r.roc
+	if r.sl < 32768 {
+		if int(seq)-int(r.sl) > 32768 && r.roc > 0 {
+			v = r.roc - 1
+		}
+	} else {
+		if int(r.sl)-32768 > int(seq) {
+			v = r.roc + 1
+		}
+	}
+
+	return uint64(v)<<16 | uint64(seq)
+}
+
+// commit updates the ROC and s_l with the index of an authenticated packet
+func (r *rocState) commit(index uint64) {
+	highest := uint64(r.roc)<<16 | uint64(r.sl)
+	if !r.initialized || index > highest {
+		r.initialized = true
+		r.roc = uint32(index >> 16)
+		r.sl = uint16(index)
+	}
+}
@@ -0,0 +1,100 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestROCGuessIndex(t *testing.T) {
+	r := new(rocState)
+	assertEqual(t, r.guessIndex(65000), uint64(65000))
+	r.commit(65000)
+
+	// s_l in the upper half, a small seq is after the wrap
+	assertEqual(t, r.guessIndex(65100), uint64(65100))
+	assertEqual(t, r.guessIndex(100), uint64(1<<16|100))
+	assertEqual(t, r.guessIndex(40000), uint64(40000))
+
+	r.commit(1<<16 | 100)
+	assertEqual(t, r.roc, uint32(1))
+	assertEqual(t, r.sl, uint16(100))
+
+	// s_l in the lower half, a large seq is from before the wrap
+	assertEqual(t, r.guessIndex(65500), uint64(65500))
+	assertEqual(t, r.guessIndex(200), uint64(1<<16|200))
+	assertEqual(t, r.guessIndex(30000), uint64(1<<16|30000))
+
+	// an older index does not move the ROC back
+	r.commit(65500)
+	assertEqual(t, r.roc, uint32(1))
+	assertEqual(t, r.sl, uint16(100))
+}
+
+func TestROCGuessIndexZero(t *testing.T) {
+	r := new(rocState)
+	r.commit(10)
+
+	// there is no ROC before zero
+	assertEqual(t, r.guessIndex(65530), uint64(65530))
+}
+
+func TestSessionROC(t *testing.T) {
+	key := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	salt := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+
+	for _, cipher := range []CipherID{SRTP_AEAD_AES_128_GCM, SRTP_AES128_CM_HMAC_SHA1_80} {
+		tx := NewRTPSession(true)
+		err := tx.SetSRTP(cipher, false, key, salt)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		rx := NewRTPSession(true)
+		err = rx.SetSRTP(cipher, false, key, salt)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		tx.seq = 65530
+
+		var packets [][]byte
+		for i := 0; i < 12; i++ {
+			p := NewRTPPacket([]byte{1, 2, 3, 4}, 8 /*pt*/, 0 /*seq*/, 33 /*ts*/, 44 /*ssrc*/)
+			data, err := tx.Encode(p)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+			packets = append(packets, data)
+		}
+		assertEqual(t, tx.txContexts[44].roc.roc, uint32(1))
+
+		// a packet from before the wrap arrives late
+		late := packets[4]
+		packets = append(packets[:4], packets[5:]...)
+
+		// a forged packet that looks like it is after the wrap must not
+		// move the ROC
+		forged := append([]byte{}, packets[3]...)
+		binary.BigEndian.PutUint16(forged[2:], 20000)
+
+		for i, data := range packets {
+			if i == 4 {
+				_, err = rx.Decode(forged)
+				if err == nil {
+					t.Fatalf("expected authentication failure")
+				}
+			}
+			p, err := rx.Decode(data)
+			if err != nil {
+				t.Fatalf("packet %d: %s", i, err.Error())
+			}
+			compareByteArrays(t, p.GetPayload(), []byte{1, 2, 3, 4})
+		}
+
+		p, err := rx.Decode(late)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		assertEqual(t, p.GetSeq(), uint16(65534))
+		assertEqual(t, rx.rxContexts[44].roc.roc, uint32(1))
+		assertEqual(t, rx.rxContexts[44].roc.sl, uint16(5))
+	}
+}